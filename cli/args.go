@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Arg describes a positional argument of a command. See ArgSpecer.
+type Arg struct {
+	// Name of the argument, like "image".
+	Name string
+	// Optional is whether the argument can be left out. Only arguments after
+	// the required ones should be optional.
+	Optional bool
+	// Variadic is whether the argument takes all the remaining arguments.
+	// Only the last argument can be variadic.
+	Variadic bool
+	// Min and Max bound the number of values of a variadic argument. A Max of
+	// 0 means there is no limit. A required variadic argument takes at least
+	// one value.
+	Min, Max int
+}
+
+// String returns the argument as it is shown in the usage line, like
+// "<image>", "[tag]", "<file>..." or "[file...]".
+func (a Arg) String() string {
+	name := a.Name
+	if a.Variadic && a.Optional {
+		name += "..."
+	}
+	if a.Optional {
+		return "[" + name + "]"
+	}
+	name = "<" + name + ">"
+	if a.Variadic {
+		name += "..."
+	}
+	return name
+}
+
+// commandArgs returns the arguments of command as they are shown in the usage
+// line, rendered from its ArgSpec if it has one.
+func commandArgs(command Command) string {
+	s, ok := command.(ArgSpecer)
+	if !ok {
+		return command.Args()
+	}
+
+	var args []string
+	for _, arg := range s.ArgSpec() {
+		args = append(args, arg.String())
+	}
+	return strings.Join(args, " ")
+}
+
+// checkArgs checks args against the ArgSpec of command, if it has one.
+func checkArgs(command Command, args []string) error {
+	s, ok := command.(ArgSpecer)
+	if !ok {
+		return nil
+	}
+	return validateArgs(s.ArgSpec(), args)
+}
+
+// validateArgs checks that args match spec.
+func validateArgs(spec []Arg, args []string) error {
+	var missing []string
+	for i, arg := range spec {
+		if i >= len(args) {
+			if !arg.Optional {
+				missing = append(missing, arg.String())
+			}
+			continue
+		}
+
+		// The variadic argument takes the rest of the arguments.
+		if arg.Variadic {
+			return validateVariadic(arg, args[i:])
+		}
+	}
+
+	if len(missing) > 0 {
+		return missingArgsError(missing)
+	}
+	if len(args) > len(spec) {
+		return fmt.Errorf("unexpected argument %q", args[len(spec)])
+	}
+	return nil
+}
+
+// validateVariadic checks the number of values of the variadic argument arg.
+func validateVariadic(arg Arg, values []string) error {
+	if arg.Min > 0 && len(values) < arg.Min {
+		return fmt.Errorf("argument %s needs at least %d values, got %d", arg, arg.Min, len(values))
+	}
+	if arg.Max > 0 && len(values) > arg.Max {
+		return fmt.Errorf("argument %s takes at most %d values, got %d", arg, arg.Max, len(values))
+	}
+	return nil
+}
+
+// missingArgsError returns the error for the missing required arguments.
+func missingArgsError(missing []string) error {
+	if len(missing) == 1 {
+		return fmt.Errorf("missing argument %s", missing[0])
+	}
+	return fmt.Errorf("missing arguments %s", strings.Join(missing, ", "))
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+// Define the copyCommand, which has an argument spec.
+type copyCommand struct {
+	args []string
+}
+
+func (cmd *copyCommand) Name() string           { return "cp" }
+func (cmd *copyCommand) Args() string           { return "ignored" }
+func (cmd *copyCommand) ShortHelp() string      { return "Copy files." }
+func (cmd *copyCommand) LongHelp() string       { return "Copy the files to the destination." }
+func (cmd *copyCommand) Hidden() bool           { return false }
+func (cmd *copyCommand) Register(*flag.FlagSet) {}
+func (cmd *copyCommand) ArgSpec() []Arg {
+	return []Arg{
+		{Name: "dest"},
+		{Name: "file", Variadic: true, Min: 1, Max: 3},
+	}
+}
+func (cmd *copyCommand) Run(ctx context.Context, args []string) error {
+	cmd.args = args
+	return nil
+}
+
+func TestArgString(t *testing.T) {
+	testCases := []struct {
+		arg      Arg
+		expected string
+	}{
+		{arg: Arg{Name: "image"}, expected: "<image>"},
+		{arg: Arg{Name: "tag", Optional: true}, expected: "[tag]"},
+		{arg: Arg{Name: "file", Variadic: true}, expected: "<file>..."},
+		{arg: Arg{Name: "file", Optional: true, Variadic: true}, expected: "[file...]"},
+	}
+
+	for _, tc := range testCases {
+		if s := tc.arg.String(); s != tc.expected {
+			t.Fatalf("expected %q, got: %q", tc.expected, s)
+		}
+	}
+}
+
+func TestValidateArgs(t *testing.T) {
+	var (
+		image = Arg{Name: "image"}
+		tag   = Arg{Name: "tag", Optional: true}
+		files = Arg{Name: "file", Variadic: true, Min: 2, Max: 3}
+		rest  = Arg{Name: "rest", Optional: true, Variadic: true}
+	)
+
+	testCases := []struct {
+		spec        []Arg
+		args        []string
+		expectedErr error
+	}{
+		{spec: []Arg{image}, args: []string{"alpine"}},
+		{spec: []Arg{image}, expectedErr: errors.New("missing argument <image>")},
+		{spec: []Arg{image, {Name: "name"}}, expectedErr: errors.New("missing arguments <image>, <name>")},
+		{spec: []Arg{image}, args: []string{"alpine", "nginx"}, expectedErr: errors.New(`unexpected argument "nginx"`)},
+		{spec: []Arg{image, tag}, args: []string{"alpine"}},
+		{spec: []Arg{image, tag}, args: []string{"alpine", "latest"}},
+		{spec: []Arg{image, tag}, args: []string{"alpine", "latest", "x"}, expectedErr: errors.New(`unexpected argument "x"`)},
+		{spec: []Arg{image, files}, args: []string{"alpine", "a", "b"}},
+		{spec: []Arg{image, files}, args: []string{"alpine"}, expectedErr: errors.New("missing argument <file>...")},
+		{spec: []Arg{image, files}, args: []string{"alpine", "a"}, expectedErr: errors.New("argument <file>... needs at least 2 values, got 1")},
+		{spec: []Arg{image, files}, args: []string{"alpine", "a", "b", "c", "d"}, expectedErr: errors.New("argument <file>... takes at most 3 values, got 4")},
+		{spec: []Arg{image, rest}, args: []string{"alpine"}},
+		{spec: []Arg{image, rest}, args: []string{"alpine", "a", "b", "c", "d"}},
+		{spec: []Arg{}, args: []string{"alpine"}, expectedErr: errors.New(`unexpected argument "alpine"`)},
+	}
+
+	for _, tc := range testCases {
+		err := validateArgs(tc.spec, tc.args)
+		compareErrors(t, err, tc.expectedErr)
+	}
+}
+
+func TestProgramArgSpec(t *testing.T) {
+	var (
+		stderr bytes.Buffer
+		cmd    = &copyCommand{}
+	)
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.Stderr = &stderr
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.Commands = []Command{cmd}
+
+	if err := p.run(p.defaultContext(), []string{"yo", "cp", "/tmp", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cmd.args, " ") != "/tmp a b" {
+		t.Fatalf("expected args /tmp a b, got: %v", cmd.args)
+	}
+
+	// Invalid arguments should be usage errors.
+	err := p.run(p.defaultContext(), []string{"yo", "cp", "/tmp"})
+	compareErrors(t, err, errors.New("missing argument <file>..."))
+	if code := ExitCode(err); code != ExitUsage {
+		t.Fatalf("expected exit code %d, got: %d", ExitUsage, code)
+	}
+
+	// The usage line should be rendered from the spec.
+	err = p.run(p.defaultContext(), []string{"yo", "cp", "-h"})
+	compareErrors(t, err, flag.ErrHelp)
+	if !strings.HasPrefix(stderr.String(), "Usage: yo cp <dest> <file>...\n") {
+		t.Fatalf("expected the usage line to be rendered from the spec, got: %q", stderr.String())
+	}
+}
@@ -0,0 +1,40 @@
+//go:build go1.18
+// +build go1.18
+
+package cli
+
+import "runtime/debug"
+
+// readBuildInfo returns the build information embedded in the binary, which is
+// empty if the binary was not built with module support.
+func readBuildInfo() buildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return buildInfo{}
+	}
+	return parseBuildInfo(info)
+}
+
+// parseBuildInfo returns the version of the main module and the vcs settings
+// of info.
+func parseBuildInfo(info *debug.BuildInfo) buildInfo {
+	var b buildInfo
+
+	// A binary built from a checkout has the version "(devel)", unless the go
+	// command could derive one from the tags.
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		b.version = v
+	}
+
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.revision = s.Value
+		case "vcs.time":
+			b.time = s.Value
+		case "vcs.modified":
+			b.modified = s.Value == "true"
+		}
+	}
+	return b
+}
@@ -0,0 +1,10 @@
+//go:build !go1.18
+// +build !go1.18
+
+package cli
+
+// readBuildInfo returns no build information, since the vcs settings are only
+// embedded in the binary since Go 1.18.
+func readBuildInfo() buildInfo {
+	return buildInfo{}
+}
@@ -0,0 +1,51 @@
+//go:build go1.18
+// +build go1.18
+
+package cli
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestParseBuildInfo(t *testing.T) {
+	testCases := []struct {
+		description string
+		info        debug.BuildInfo
+		expected    buildInfo
+	}{
+		{
+			description: "empty",
+			expected:    buildInfo{},
+		},
+		{
+			description: "checkout",
+			info: debug.BuildInfo{
+				Main: debug.Module{Path: "github.com/genuinetools/yo", Version: "(devel)"},
+				Settings: []debug.BuildSetting{
+					{Key: "-compiler", Value: "gc"},
+					{Key: "vcs", Value: "git"},
+					{Key: "vcs.revision", Value: "def456"},
+					{Key: "vcs.time", Value: "2026-01-02T03:04:05Z"},
+					{Key: "vcs.modified", Value: "true"},
+				},
+			},
+			expected: buildInfo{revision: "def456", time: "2026-01-02T03:04:05Z", modified: true},
+		},
+		{
+			description: "module version",
+			info: debug.BuildInfo{
+				Main: debug.Module{Path: "github.com/genuinetools/yo", Version: "v1.2.3"},
+			},
+			expected: buildInfo{version: "v1.2.3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if b := parseBuildInfo(&tc.info); b != tc.expected {
+				t.Fatalf("expected: %+v\ngot: %+v", tc.expected, b)
+			}
+		})
+	}
+}
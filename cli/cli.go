@@ -5,11 +5,14 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 const (
@@ -19,8 +22,14 @@ const (
 	NameKey ContextKey = "program.Name"
 	// VersionKey is the key for the program's Version data.
 	VersionKey ContextKey = "program.Version"
+
+	// programKey is the key for the copy of the program that is running.
+	programKey ContextKey = "program"
 )
 
+// defaultVersion is the Version of a new Program.
+const defaultVersion = "0.0.0"
+
 // ContextKey defines the type for holding keys in the context.
 type ContextKey string
 
@@ -30,16 +39,76 @@ type Program struct {
 	Name string
 	// Description of the program.
 	Description string
-	// Version of the program.
+	// Version of the program. If empty or left at the default, the version
+	// command shows the version of the main module from the build information.
 	Version string
-	// GitCommit information for the program.
+	// GitCommit information for the program. If empty, the version command
+	// shows the vcs revision from the build information.
 	GitCommit string
 
+	// GracePeriod is how long a program started with Run has to return after
+	// its context was canceled by an interrupt or termination signal, before
+	// it is forced to exit. A second signal always forces the program to exit.
+	// Defaults to waiting until the program returns.
+	GracePeriod time.Duration
+
+	// Stdin is the input stream of the program. Defaults to os.Stdin.
+	Stdin io.Reader
+	// Stdout is the output stream of the program. Defaults to os.Stdout.
+	Stdout io.Writer
+	// Stderr is the stream the program writes errors and usage to.
+	// Defaults to os.Stderr.
+	Stderr io.Writer
+
 	// Commands in the program.
 	Commands []Command
-	// FlagSet holds the common/global flags for the program.
+	// FlagSet holds the common/global flags for the program. Its
+	// ErrorHandling is not used: the errors from parsing the flags are
+	// returned, so Run exits with ExitUsage and RunContext does not exit.
 	FlagSet *flag.FlagSet
 
+	// GNUFlags enables parsing the flags POSIX/GNU style, instead of the style
+	// of the flag package. Flags with single character names are short flags
+	// that take a single dash, can be bundled like "-abc" and take their value
+	// as the next argument or directly attached, like "-ofile". Flags with
+	// longer names are long flags that take a double dash, like "--debug",
+	// and take their value as the next argument or after an equals sign, like
+	// "--output=file". Use Alias to pair short and long flags.
+	GNUFlags bool
+
+	// InterspersedFlags allows flags after the positional arguments, like
+	// "prog sub image -f", instead of stopping at the first positional
+	// argument. A "--" still ends the flags. When a command has subcommands,
+	// the flags up to the name of the subcommand are parsed for the command
+	// and the rest for the subcommand.
+	InterspersedFlags bool
+
+	// EnvPrefix enables setting flags from the environment. Any flag, global
+	// or for a command, not set on the command line is set from the variable
+	// named by the prefix and the flag name, like REG_DEBUG for the flag
+	// "debug" with the prefix "REG_".
+	EnvPrefix string
+
+	// LoadConfig enables setting flags from a JSON config file, read from the
+	// path given by the --config flag. Global flags are set from the top-level
+	// values of the file and the flags of a command from the object named
+	// after it, or the objects of its parents, with nested objects for
+	// subcommands:
+	//
+	//	{"debug": true, "registry": {"host": "r.j3ss.co", "tags": {"list": {"all": true}}}}
+	//
+	// A flag set on the command line takes precedence over the environment
+	// (see EnvPrefix), which takes precedence over the config file, which
+	// takes precedence over the flag's default.
+	LoadConfig bool
+	// ConfigFile is the default path of the config file.
+	// Defaults to DefaultConfigFile(Name).
+	ConfigFile string
+
+	// PrefixMatching allows any unambiguous prefix of a command name, or of
+	// one of its aliases, to be used to run that command.
+	PrefixMatching bool
+
 	// Before defines a function to execute before any subcommands are run,
 	// but after the context is ready.
 	// If a non-nil error is returned, no subcommands are run.
@@ -47,11 +116,23 @@ type Program struct {
 	// After defines a function to execute after any commands or action is run
 	// and has finished.
 	// It is run _only_ if the subcommand exits without an error.
+	// Its context is not canceled by signals, so it can still clean up after
+	// the program was interrupted.
 	After func(context.Context) error
 
+	// Finally defines a function to execute last, whatever happened, with the
+	// error the program is about to return or nil on success. Use it to release
+	// locks or flush logs even if the command failed.
+	// If the program succeeded, the error it returns is returned instead.
+	Finally func(context.Context, error) error
+
 	// Action is the function to execute when no subcommands are specified.
 	// It gives the user back the arguments after the flags have been parsed.
 	Action func(context.Context, []string) error
+
+	// usagePrinted is whether the usage was printed during a run, set on the
+	// copy of the program made for the run.
+	usagePrinted bool
 }
 
 // Command defines the interface for each command in a program.
@@ -64,78 +145,247 @@ type Command interface {
 	// Hidden indicates whether the command should be hidden from the help output.
 	Hidden() bool
 
-	// Register command specific flags.
+	// Register command specific flags. The FlagSet is the command's own,
+	// layered over the global flags and the flags of its parent commands.
 	Register(*flag.FlagSet)
 	// Run executes the function for the command with a context and the command arguments.
 	Run(context.Context, []string) error
 }
 
+// Aliaser is an optional interface a Command can implement to be run by other
+// names as well, like "ls" for "list" or "rm" for "remove".
+type Aliaser interface {
+	Aliases() []string
+}
+
+// Beforer is an optional interface a Command can implement to execute a
+// function before its Run, after the program's Before. If a non-nil error is
+// returned, the command is not run.
+type Beforer interface {
+	Before(context.Context) error
+}
+
+// Afterer is an optional interface a Command can implement to execute a
+// function after its Run, before the program's After. Unlike the program's
+// After, it is run even if the command failed, as long as the command's Before
+// succeeded, so it can tear down whatever Before set up.
+type Afterer interface {
+	After(context.Context) error
+}
+
+// Completer is an optional interface a Command can implement to complete its
+// arguments in the shell completions, for arguments like image names or
+// container IDs. It is given the arguments before the word being completed
+// and the partial word, and returns the candidates starting with it.
+// The flags on the command line are parsed before it is called, but the Before
+// functions are not run.
+type Completer interface {
+	Complete(ctx context.Context, args []string, toComplete string) []string
+}
+
+// ArgSpecer is an optional interface a Command can implement to describe its
+// positional arguments. The arguments are checked against the spec before the
+// Before functions are run, and the usage line is rendered from it instead of
+// from Args.
+type ArgSpecer interface {
+	ArgSpec() []Arg
+}
+
+// Subcommander is an optional interface a Command can implement to expose
+// child commands, allowing for trees like `prog registry tags list`.
+//
+// Each level of the tree registers and parses its own flags before the next
+// argument is matched against its children. If the remaining arguments do not
+// name a child command, the parent's Run is executed instead, so a command that
+// only groups others can return flag.ErrHelp to print its usage.
+type Subcommander interface {
+	Subcommands() []Command
+}
+
 // NewProgram creates a new Program with some reasonable defaults for Name,
 // Description, and Version.
 func NewProgram() *Program {
 	return &Program{
 		Name:        filepath.Base(os.Args[0]),
 		Description: "A new command line program.",
-		Version:     "0.0.0",
+		Version:     defaultVersion,
 	}
 }
 
 // Run is the entry point for the program. It parses the arguments and executes
 // the commands.
+//
+// The context passed to Before, Action and the commands is canceled when the
+// program receives an interrupt or termination signal, instead of the program
+// being killed. A notice is printed, and the program keeps running until it
+// returns, so Before, Action and the commands should stop once the context is
+// done. A second signal forces the program to exit. See GracePeriod.
 func (p *Program) Run() {
-	ctx := p.defaultContext()
+	ctx, stop := p.handleSignals(context.Background())
 
 	// Pass the os.Args through so we can more easily unit test.
-	err := p.run(ctx, os.Args)
+	r := p.copy()
+	err := r.execUsage(r.newContext(ctx), os.Args)
+	stop()
 	if err == nil {
 		// Return early if there was no error.
 		return
 	}
 
-	if err != flag.ErrHelp {
-		// We did not return the error to print the usage, so let's print the
-		// error and exit.
-		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(1)
+	// Print the error, unless we returned it to print the usage or the flag
+	// package printed it along with the usage.
+	if !isHelp(err) && !r.usagePrinted {
+		fmt.Fprintln(p.stderr(), err.Error())
 	}
 
-	// Print the usage.
-	p.FlagSet.Usage()
-	os.Exit(1)
+	os.Exit(ExitCode(err))
+}
+
+// RunContext parses the arguments and executes the commands with a context
+// derived from ctx. Unlike Run it does not exit, making it possible to embed a
+// Program in another program or a test. The args should start with the name
+// of the program, like os.Args.
+//
+// If the usage was requested, flag.ErrHelp is returned. If no command could be
+// run, an ExitError wrapping flag.ErrHelp with the ExitUsage code is returned.
+// In both cases the usage is printed. Any other error is returned without
+// printing. Use ExitCode to get the code the program should exit with.
+//
+// Running the program does not modify it, so it can be run many times, even
+// at the same time. The flags keep the values they were set to in the
+// variables they are bound to, so programs run at the same time should not
+// share flags.
+func (p *Program) RunContext(ctx context.Context, args []string) error {
+	r := p.copy()
+	return r.execUsage(r.newContext(ctx), args)
 }
 
+// run runs a copy of the program with args, so running it does not modify p.
 func (p *Program) run(ctx context.Context, args []string) error {
-	// Append the version command to the list of commands by default.
-	p.Commands = append(p.Commands, &versionCommand{})
+	return p.copy().exec(ctx, args)
+}
 
-	// Set the default flagset if our flagset is undefined.
-	if p.FlagSet == nil {
-		p.FlagSet = defaultFlagSet(p.Name)
+// copy returns a copy of the program to be run once, with its own list of
+// commands and its own FlagSet holding the global flags. The FlagSet continues
+// on errors, so parsing the flags never exits.
+func (p *Program) copy() *Program {
+	r := *p
+	r.Commands = append([]Command(nil), p.Commands...)
+
+	// Use the default flagset if our flagset is undefined.
+	fs := p.FlagSet
+	if fs == nil {
+		fs = defaultFlagSet(p.Name)
+	}
+	r.FlagSet = flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	r.FlagSet.SetOutput(fs.Output())
+	inheritFlags(r.FlagSet, fs)
+
+	return &r
+}
+
+// documented returns a copy of the program as it is documented, with the
+// built-in commands and the flag for the config file. The default of the flag
+// is shown as a placeholder rather than the path on this machine, so the
+// documentation does not depend on where it is generated.
+func (p *Program) documented() *Program {
+	r := p.copy()
+	r.Commands = append(r.Commands, r.builtins()...)
+	r.registerConfigFlag(r.FlagSet)
+	setConfigFlagDoc(r.FlagSet, p)
+	return r
+}
+
+// builtins returns the commands added to every program.
+func (p *Program) builtins() []Command {
+	return []Command{&versionCommand{}, &completionCommand{p: p}, &completeCommand{p: p}}
+}
+
+// isBuiltin returns whether command is one of the commands from builtins.
+func isBuiltin(command Command) bool {
+	switch command.(type) {
+	case *versionCommand, *completionCommand, *completeCommand:
+		return true
+	}
+	return false
+}
+
+// execUsage runs exec and prints the usage if it was requested or no command
+// could be run, unless it was printed already.
+func (p *Program) execUsage(ctx context.Context, args []string) error {
+	err := p.exec(ctx, args)
+	if isHelp(err) && !p.usagePrinted {
+		// Print the usage.
+		p.FlagSet.Usage()
+	}
+	return err
+}
+
+// exec parses the arguments and executes the commands. It modifies p, so it
+// is run on a copy of the program.
+func (p *Program) exec(ctx context.Context, args []string) (err error) {
+	ctx = context.WithValue(ctx, programKey, p)
+
+	// Run the finally function once we are done, whatever happened.
+	if p.Finally != nil {
+		defer func() {
+			if ferr := p.Finally(withoutCancel(ctx), err); err == nil {
+				err = ferr
+			}
+		}()
+	}
+
+	// Append the version and completion commands to the list of commands by
+	// default.
+	builtins := p.builtins()
+	p.Commands = append(p.Commands, builtins...)
+
+	// Add the flag for the config file.
+	p.registerConfigFlag(p.FlagSet)
+
+	// Send the flag parsing errors to our error stream.
+	if p.Stderr != nil {
+		p.FlagSet.SetOutput(p.Stderr)
 	}
 
 	// Override the usage text to something nicer.
 	p.FlagSet.Usage = func() {
 		p.usage(ctx)
+		p.usagePrinted = true
 	}
 
-	// IF
-	// args is <nil>
-	// OR
-	// args is less than 1
-	// OR
-	// we have more than one arg and it equals help OR is a help flag
-	// THEN
-	// print the usage
-	if args == nil ||
-		len(args) < 1 ||
-		(len(args) > 1 && contains([]string{"-h", "--help", "help"}, args[1])) {
+	// If args is <nil> or less than 1, print the usage.
+	if args == nil || len(args) < 1 {
+		return usageError(flag.ErrHelp)
+	}
+
+	// If we have more than one arg and it equals help OR is a help flag, print
+	// the usage since it was requested.
+	if len(args) > 1 && contains([]string{"-h", "--help", "help"}, args[1]) {
 		return flag.ErrHelp
 	}
 
 	// If we do not have an action set and we have no commands, print the usage
 	// and exit.
-	if p.Action == nil && len(p.Commands) < 2 {
-		return flag.ErrHelp
+	if p.Action == nil && len(p.Commands) <= len(builtins) {
+		return usageError(flag.ErrHelp)
+	}
+
+	// Parse the global flags before the command name, like `prog -d sub`, and
+	// dispatch on the argument after them.
+	globalsParsed := false
+	if len(args) > 1 && strings.HasPrefix(args[1], "-") && args[1] != "-" {
+		rest, err := p.parseGlobalFlags(args[1:])
+		if err != nil {
+			return err
+		}
+		// Leave the flags parsed for the action if the rest does not name a
+		// command.
+		if p.Action == nil || (len(rest) > 0 && p.isCommand(rest[0])) {
+			args = append([]string{args[0]}, rest...)
+		} else {
+			globalsParsed = true
+		}
 	}
 
 	// Check if the command exists.
@@ -144,17 +394,21 @@ func (p *Program) run(ctx context.Context, args []string) error {
 		commandExists bool
 	)
 	if len(args) > 1 {
-		command = p.findCommand(args[1])
+		var err error
+		command, err = p.findCommand(args[1])
+		if err != nil {
+			return usageError(err)
+		}
 		commandExists = command != nil
 	}
 
 	// Return early if we didn't enter the single action logic and
 	// the command does not exist or we were passed no commands.
 	if p.Action == nil && len(args) < 2 {
-		return flag.ErrHelp
+		return usageError(flag.ErrHelp)
 	}
 	if p.Action == nil && !commandExists {
-		return fmt.Errorf("%s: no such command", args[1])
+		return usageError(noSuchCommandError(args[1], p.Commands))
 	}
 
 	// If we are not running a command we know, then automatically
@@ -162,11 +416,21 @@ func (p *Program) run(ctx context.Context, args []string) error {
 	// Also enter this loop if we weren't passed any arguments.
 	if p.Action != nil &&
 		(len(args) < 2 || !commandExists) {
-		// Parse the flags the user gave us.
-		if err := p.FlagSet.Parse(args[1:]); err != nil {
+		// Parse the flags the user gave us, unless they were parsed already,
+		// so the flags that can be repeated are not set twice.
+		if globalsParsed {
+			if err := p.setUnsetFlags(p.FlagSet, nil); err != nil {
+				return err
+			}
+		} else if err := p.parseFlags(p.FlagSet, args[1:], nil); err != nil {
 			return err
 		}
 
+		// Check the flags against their declared constraints.
+		if err := validateFlags(p.FlagSet, nil); err != nil {
+			return usageError(err)
+		}
+
 		// Run the main action _if_ we are not in the loop for the version command
 		// that is added by default.
 		if p.Before != nil {
@@ -182,41 +446,50 @@ func (p *Program) run(ctx context.Context, args []string) error {
 	}
 
 	if commandExists {
-		// Register the subcommand flags in with the common/global flags.
-		command.Register(p.FlagSet)
-
-		// Override the usage text to something nicer.
-		p.resetCommandUsage(command)
-
-		// Parse the flags the user gave us.
-		if err := p.FlagSet.Parse(args[2:]); err != nil {
+		// Walk down the tree of subcommands, registering and parsing the flags
+		// for each level along the way.
+		path, fs, err := p.resolveCommand(command, args[2:])
+		if err != nil {
 			return err
 		}
 
-		// Check that they didn't add a -h or --help flag after the subcommand's
-		// commands, like `cmd sub other thing -h`.
-		if contains([]string{"-h", "--help"}, args...) {
-			// Print the flag usage and exit.
-			return flag.ErrHelp
+		// Check the flags against their declared constraints. The version and
+		// completion commands we supply do not use the global flags, so they
+		// only check their own flags and arguments.
+		builtin := isBuiltin(path[0])
+		var ignore *flag.FlagSet
+		if builtin {
+			ignore = p.FlagSet
+		}
+		if err := validateFlags(fs, ignore); err != nil {
+			return usageError(err)
+		}
+
+		// Check the arguments against the spec of the command, if any.
+		if !builtin {
+			if err := checkArgs(path[len(path)-1], fs.Args()); err != nil {
+				return usageError(err)
+			}
 		}
 
 		// Only execute the Before function for user-supplied commands.
-		// This excludes the version command we supply.
-		if p.Before != nil && command.Name() != "version" {
+		// This excludes the version and completion commands we supply.
+		if p.Before != nil && !builtin {
 			if err := p.Before(ctx); err != nil {
 				return err
 			}
 		}
 
-		// Run the command with the context and post-flag-processing args.
-		if err := command.Run(ctx, p.FlagSet.Args()); err != nil {
+		// Run the command and its hooks with the context and
+		// post-flag-processing args.
+		if err := runCommand(ctx, path, fs.Args()); err != nil {
 			return err
 		}
 	}
 
 	// Run the after function.
 	if p.After != nil {
-		if err := p.After(ctx); err != nil {
+		if err := p.After(withoutCancel(ctx)); err != nil {
 			return err
 		}
 	}
@@ -225,46 +498,315 @@ func (p *Program) run(ctx context.Context, args []string) error {
 	return nil
 }
 
+// helpRequested returns whether the arguments fs stopped parsing at hold a -h
+// or --help flag, like `cmd sub other thing -h`. It returns false if the flags
+// were ended by "--", and stops at a later "--". The flags fs parsed are not
+// checked, so the value of a flag is never taken for a -h flag.
+func helpRequested(fs *flag.FlagSet, args []string, gnu bool) bool {
+	rest := fs.Args()
+	if terminated(fs, args[:len(args)-len(rest)], gnu) {
+		return false
+	}
+	for _, arg := range rest {
+		if arg == "--" {
+			return false
+		}
+		if arg == "-h" || arg == "--help" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCommand walks down the tree of subcommands starting at command. At
+// each level it creates the command's FlagSet and parses the arguments, then
+// descends into the child named by the first remaining argument, if any.
+// It returns the path of commands from the top-level command to the one that
+// should be run, and the FlagSet of that command.
+func (p *Program) resolveCommand(command Command, args []string) ([]Command, *flag.FlagSet, error) {
+	var (
+		path = []Command{command}
+		fs   = p.FlagSet
+	)
+	for {
+		// Layer the command flags over the flags of its parent.
+		var err error
+		fs, err = p.commandFlagSet(path, fs)
+		if err != nil {
+			return path, fs, err
+		}
+
+		// Override the usage text to something nicer.
+		p.resetCommandUsage(path, fs)
+
+		// Parse the flags the user gave us.
+		if err := p.parseFlags(fs, args, path); err != nil {
+			return path, fs, err
+		}
+
+		// Stop if the command has no children or we have no more arguments.
+		parent, ok := command.(Subcommander)
+		if !ok || fs.NArg() < 1 {
+			break
+		}
+
+		// Stop if the next argument is not one of the children.
+		child, err := findCommand(parent.Subcommands(), fs.Arg(0), p.PrefixMatching)
+		if err != nil {
+			return path, fs, usageError(err)
+		}
+		if child == nil {
+			break
+		}
+
+		command = child
+		path = append(path, child)
+		args = fs.Args()[1:]
+	}
+
+	// Check that they didn't add a -h or --help flag after the command's
+	// arguments, where the flag parsing stopped.
+	if !p.InterspersedFlags && helpRequested(fs, args, p.GNUFlags) {
+		return path, fs, flag.ErrHelp
+	}
+	return path, fs, nil
+}
+
+// commandFlagSet returns a new FlagSet for the command at the end of path,
+// holding the flags of parent and the flags the command registers. The flags
+// share their values with parent, so setting a global flag on the command line
+// of a command sets the global variable. It returns an error if the command
+// registers a flag that parent already has, unless it is one of the built-in
+// commands, which then go without their flag.
+func (p *Program) commandFlagSet(path []Command, parent *flag.FlagSet) (*flag.FlagSet, error) {
+	name := commandPath(path)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(parent.Output())
+	inheritFlags(fs, parent)
+
+	var (
+		err     error
+		builtin = isBuiltin(path[0])
+	)
+	commandFlags(path[len(path)-1]).VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		if fs.Lookup(f.Name) != nil {
+			// The built-in commands leave the name to the program's flag.
+			if builtin {
+				return
+			}
+
+			owner := "a global flag"
+			if p.FlagSet.Lookup(f.Name) == nil {
+				owner = "a flag of a parent command"
+			}
+			err = fmt.Errorf("%s: flag %s is already defined as %s", name, flagName(f.Name), owner)
+			return
+		}
+		fs.Var(f.Value, f.Name, f.Usage)
+		fs.Lookup(f.Name).DefValue = f.DefValue
+	})
+	return fs, err
+}
+
+// runCommand runs the last command in path, wrapped by the Before and After
+// functions of every command in path. The Before functions are run from the
+// top-level command down and the After functions in the reverse order.
+func runCommand(ctx context.Context, path []Command, args []string) error {
+	for i, command := range path {
+		if b, ok := command.(Beforer); ok {
+			if err := b.Before(ctx); err != nil {
+				// Tear down the commands that were already set up.
+				return runAfters(ctx, path[:i], err)
+			}
+		}
+	}
+
+	err := path[len(path)-1].Run(ctx, args)
+	return runAfters(ctx, path, err)
+}
+
+// runAfters runs the After functions of the commands in path in reverse order
+// and returns err, or the first error from an After function if err is nil.
+func runAfters(ctx context.Context, path []Command, err error) error {
+	for i := len(path) - 1; i >= 0; i-- {
+		if a, ok := path[i].(Afterer); ok {
+			if aerr := a.After(withoutCancel(ctx)); err == nil {
+				err = aerr
+			}
+		}
+	}
+	return err
+}
+
+// parseFlags parses args into fs, then sets the flags that were not set on the
+// command line from the environment and the config file section for the
+// command at the end of path.
+func (p *Program) parseFlags(fs *flag.FlagSet, args []string, path []Command) error {
+	err := p.parseArgs(fs, args, func(arg string) bool {
+		return p.isSubcommand(path, arg)
+	})
+	if err != nil {
+		return flagError(err)
+	}
+
+	return p.setUnsetFlags(fs, path)
+}
+
+// setUnsetFlags sets the flags in fs that were not set on the command line
+// from the environment and the config file section for the command at the end
+// of path.
+func (p *Program) setUnsetFlags(fs *flag.FlagSet, path []Command) error {
+	if err := p.setFlagsFromEnv(fs); err != nil {
+		return usageError(err)
+	}
+
+	if err := p.setFlagsFromConfig(fs, path); err != nil {
+		return usageError(err)
+	}
+
+	return nil
+}
+
+// parse parses args into fs up to the first positional argument.
+func (p *Program) parse(fs *flag.FlagSet, args []string) error {
+	if p.GNUFlags {
+		return parseGNU(fs, args)
+	}
+	return fs.Parse(args)
+}
+
+// parseArgs parses args into fs. With InterspersedFlags, the flags after the
+// positional arguments are parsed too, unless the first one is a command name
+// according to isCommand.
+func (p *Program) parseArgs(fs *flag.FlagSet, args []string, isCommand func(string) bool) error {
+	if !p.InterspersedFlags {
+		return p.parse(fs, args)
+	}
+	return parseInterspersed(fs, args, p.GNUFlags, func(args []string) error {
+		return p.parse(fs, args)
+	}, isCommand)
+}
+
+// parseGlobalFlags parses the global flags at the start of args and returns
+// the arguments after them, starting with the command name, if any.
+func (p *Program) parseGlobalFlags(args []string) ([]string, error) {
+	if err := p.parseArgs(p.FlagSet, args, p.isCommand); err != nil {
+		return nil, flagError(err)
+	}
+	return p.FlagSet.Args(), nil
+}
+
+// isSubcommand returns whether arg names a child of the command at the end of
+// path, or is ambiguous between them.
+func (p *Program) isSubcommand(path []Command, arg string) bool {
+	if len(path) < 1 {
+		return false
+	}
+	parent, ok := path[len(path)-1].(Subcommander)
+	if !ok {
+		return false
+	}
+	child, err := findCommand(parent.Subcommands(), arg, p.PrefixMatching)
+	return child != nil || err != nil
+}
+
 func (p *Program) usage(ctx context.Context) error {
-	fmt.Fprintf(os.Stderr, "%s -  %s.\n\n", p.Name, strings.TrimSuffix(strings.TrimSpace(p.Description), "."))
-	fmt.Fprintf(os.Stderr, "Usage: %s <command>\n", p.Name)
-	fmt.Fprintln(os.Stderr)
+	out := p.stderr()
+
+	fmt.Fprintf(out, "%s -  %s.\n\n", p.Name, strings.TrimSuffix(strings.TrimSpace(p.Description), "."))
+	fmt.Fprintf(out, "Usage: %s <command>\n", p.Name)
+	fmt.Fprintln(out)
 
 	// Print information about the common/global flags.
 	if p.FlagSet != nil {
-		resetFlagUsage(p.FlagSet)
+		p.resetFlagUsage(out, p.FlagSet)
 	}
 
 	// Print information about the commands.
-	fmt.Fprintln(os.Stderr, "Commands:")
-	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out)
 
-	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
-	for _, command := range p.Commands {
-		if !command.Hidden() {
-			fmt.Fprintf(w, "\t%s\t%s\n", command.Name(), command.ShortHelp())
-		}
-	}
-	w.Flush()
+	printCommands(out, p.Commands)
 
-	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(out)
 	return nil
 }
 
-func (p *Program) resetCommandUsage(command Command) {
-	p.FlagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s %s %s\n", p.Name, command.Name(), command.Args())
-		fmt.Fprintln(os.Stderr)
-		fmt.Fprintln(os.Stderr, strings.TrimSpace(command.LongHelp()))
-		fmt.Fprintln(os.Stderr)
-		resetFlagUsage(p.FlagSet)
+func (p *Program) resetCommandUsage(path []Command, fs *flag.FlagSet) {
+	command := path[len(path)-1]
+
+	usage := func() {
+		out := p.stderr()
+
+		fmt.Fprintf(out, "Usage: %s %s %s\n", p.Name, commandPath(path), commandArgs(command))
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, strings.TrimSpace(command.LongHelp()))
+		fmt.Fprintln(out)
+
+		// Print the flags of the command, then the global flags.
+		var commandFlags, globalFlags []mflag
+		for _, f := range p.flagList(fs) {
+			if p.FlagSet.Lookup(f.names[0]) != nil {
+				globalFlags = append(globalFlags, f)
+				continue
+			}
+			commandFlags = append(commandFlags, f)
+		}
+		printFlagGroups(out, "Flags", commandFlags)
+		printFlagGroups(out, "Global flags", globalFlags)
+
+		// Print information about the child commands, if any.
+		if parent, ok := command.(Subcommander); ok {
+			fmt.Fprintln(out, "Commands:")
+			fmt.Fprintln(out)
+			printCommands(out, parent.Subcommands())
+			fmt.Fprintln(out)
+		}
 	}
+	fs.Usage = func() {
+		usage()
+		p.usagePrinted = true
+	}
+	p.FlagSet.Usage = fs.Usage
+}
+
+// printCommands prints a table of the commands that are not hidden.
+func printCommands(out io.Writer, commands []Command) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, command := range commands {
+		if !command.Hidden() {
+			fmt.Fprintf(w, "\t%s\t%s\n", strings.Join(commandNames(command), ", "), command.ShortHelp())
+		}
+	}
+	w.Flush()
 }
 
 type mflag struct {
+	// name of the flag as it is shown, like "-d, --debug".
 	name     string
 	defValue string
 	usage    string
+	env      string
+	// required is whether the flag must be set, in which case it has no
+	// default.
+	required bool
+	// names of the flag, including its shortcodes and aliases.
+	names []string
+	// group is the title of the section the flag is shown in, if any.
+	group      string
+	groupOrder int
+}
+
+// defaultText returns "(required)" for a required flag, otherwise the default
+// value of the flag, like "(default: <none>)".
+func (f mflag) defaultText() string {
+	if f.required {
+		return "(required)"
+	}
+	return fmt.Sprintf("(default: %s)", f.defValue)
 }
 
 // byName implements sort.Interface for []mflag based on the name field.
@@ -273,20 +815,90 @@ type byName []mflag
 func (n byName) Len() int      { return len(n) }
 func (n byName) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
 func (n byName) Less(i, j int) bool {
-	return strings.TrimPrefix(n[i].name, "-") < strings.TrimPrefix(n[j].name, "-")
+	return strings.TrimLeft(n[i].name, "-") < strings.TrimLeft(n[j].name, "-")
+}
+
+func (p *Program) resetFlagUsage(out io.Writer, fs *flag.FlagSet) {
+	printFlagGroups(out, "Flags", p.flagList(fs))
 }
 
-func resetFlagUsage(fs *flag.FlagSet) {
+// printFlagGroups prints the flags that are not in a group in a section with
+// title, then a section for each group.
+func printFlagGroups(out io.Writer, title string, flagMap []mflag) {
+	// Split the flags into the ungrouped flags and the groups, ordered by
+	// when the groups were declared.
+	var (
+		ungrouped []mflag
+		groups    []string
+		grouped   = map[string][]mflag{}
+		order     = map[string]int{}
+	)
+	for _, f := range flagMap {
+		if f.group == "" {
+			ungrouped = append(ungrouped, f)
+			continue
+		}
+		if _, ok := grouped[f.group]; !ok {
+			groups = append(groups, f.group)
+			order[f.group] = f.groupOrder
+		}
+		grouped[f.group] = append(grouped[f.group], f)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return order[groups[i]] < order[groups[j]]
+	})
+
+	printFlags(out, title, ungrouped)
+	for _, group := range groups {
+		printFlags(out, group, grouped[group])
+	}
+}
+
+// printFlags prints a section with title and a table of the flags, if any.
+func printFlags(out io.Writer, title string, flagMap []mflag) {
+	if len(flagMap) < 1 {
+		return // Return early.
+	}
+
 	var (
-		hasFlags   bool
 		flagBlock  bytes.Buffer
-		flagMap    = []mflag{}
 		flagWriter = tabwriter.NewWriter(&flagBlock, 0, 4, 2, ' ', 0)
 	)
 
+	for i := 0; i < len(flagMap); i++ {
+		var env string
+		if flagMap[i].env != "" {
+			env = fmt.Sprintf(" [$%s]", flagMap[i].env)
+		}
+		fmt.Fprintf(flagWriter, "\t%s\t%s %s%s\n", flagMap[i].name, flagMap[i].usage, flagMap[i].defaultText(), env)
+	}
+
+	flagWriter.Flush()
+
+	fmt.Fprintf(out, "%s:\n", title)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, flagBlock.String())
+}
+
+// flagList returns the flags in fs sorted by name, with the aliases of a flag
+// combined.
+func (p *Program) flagList(fs *flag.FlagSet) []mflag {
+	var (
+		flags   []*flag.Flag
+		aliases []*flag.Flag
+		flagMap = []mflag{}
+	)
+
+	// Handle the aliases after the flags they are aliases of.
 	fs.VisitAll(func(f *flag.Flag) {
-		hasFlags = true
+		if flagMeta(f).alias != "" {
+			aliases = append(aliases, f)
+			return
+		}
+		flags = append(flags, f)
+	})
 
+	for _, f := range flags {
 		// Default-empty string vars should read "(default: <none>)"
 		// rather than the comparatively ugly "(default: )".
 		defValue := f.DefValue
@@ -294,66 +906,164 @@ func resetFlagUsage(fs *flag.FlagSet) {
 			defValue = "<none>"
 		}
 
-		// Add a double dash if the name is only one character long.
-		name := f.Name
-		if len(name) > 1 {
-			name = "-" + name
+		// Show the values the flag can be set to, if restricted.
+		m := flagMeta(f)
+		allowed := m.allowed
+		if a, ok := m.Value.(allower); ok && len(allowed) < 1 {
+			allowed = a.Allowed()
+		}
+		usage := f.Usage
+		if len(allowed) > 0 {
+			usage = fmt.Sprintf("%s (one of: %s)", usage, strings.Join(allowed, ", "))
 		}
 
-		// Try and find duplicates (or the shortcode flags and combine them.
-		// Like: -, --password
+		flagMap = append(flagMap, mflag{
+			defValue:   defValue,
+			usage:      usage,
+			required:   m.required,
+			names:      []string{f.Name},
+			group:      m.group,
+			groupOrder: m.groupOrder,
+		})
+	}
+
+	// Add the aliases to the flags they are aliases of.
+	for _, f := range aliases {
+		target := flagMeta(f).alias
 		for k, v := range flagMap {
-			if v.usage == f.Usage {
-				if len(v.name) <= 2 {
-					// We already had the shortcode, let's append.
-					v.name = fmt.Sprintf("%s, -%s", v.name, name)
-				} else {
-					v.name = fmt.Sprintf("%s, -%s", name, v.name)
-				}
-				flagMap[k].name = v.name
-
-				// Return here.
-				return
+			if contains(v.names, target) {
+				flagMap[k].names = append(flagMap[k].names, f.Name)
+				break
 			}
 		}
+	}
 
-		flagMap = append(flagMap, mflag{
-			name:     name,
-			defValue: defValue,
-			usage:    f.Usage,
-		})
-	})
+	for k, v := range flagMap {
+		// Show the shortcodes first, then the long names.
+		sort.Sort(byLength(v.names))
+
+		names := make([]string, 0, len(v.names))
+		for _, n := range v.names {
+			names = append(names, flagName(n))
+		}
+		flagMap[k].name = strings.Join(names, ", ")
+
+		// Show the environment variable for the longest name, if enabled.
+		if p.EnvPrefix != "" {
+			flagMap[k].env = envName(p.EnvPrefix, v.names[len(v.names)-1])
+		}
+	}
 
 	// Sort by name and preserve order on output.
 	sort.Sort(byName(flagMap))
-	for i := 0; i < len(flagMap); i++ {
-		fmt.Fprintf(flagWriter, "\t-%s\t%s (default: %s)\n", flagMap[i].name, flagMap[i].usage, flagMap[i].defValue)
-	}
+	return flagMap
+}
 
-	flagWriter.Flush()
+// byLength implements sort.Interface for []string based on the length, then
+// the value of the strings.
+type byLength []string
 
-	if !hasFlags {
-		return // Return early.
+func (n byLength) Len() int      { return len(n) }
+func (n byLength) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+func (n byLength) Less(i, j int) bool {
+	if len(n[i]) != len(n[j]) {
+		return len(n[i]) < len(n[j])
 	}
-
-	fmt.Fprintln(os.Stderr, "Flags:")
-	fmt.Fprintln(os.Stderr)
-	fmt.Fprintln(os.Stderr, flagBlock.String())
+	return n[i] < n[j]
 }
 
 func defaultFlagSet(n string) *flag.FlagSet {
 	// Create the default flagset with a debug flag.
-	return flag.NewFlagSet(n, flag.ExitOnError)
+	return flag.NewFlagSet(n, flag.ContinueOnError)
+}
+
+// visitCommands calls fn for each command in the tree under commands that is
+// not hidden, in order, with the path of commands from the top-level command.
+func visitCommands(path []Command, commands []Command, fn func([]Command)) {
+	for _, command := range commands {
+		if command.Hidden() {
+			continue
+		}
+
+		p := append(append([]Command{}, path...), command)
+		fn(p)
+
+		if parent, ok := command.(Subcommander); ok {
+			visitCommands(p, parent.Subcommands(), fn)
+		}
+	}
+}
+
+// commandFlags returns a new FlagSet with only the flags of command registered.
+func commandFlags(command Command) *flag.FlagSet {
+	fs := flag.NewFlagSet(command.Name(), flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	command.Register(fs)
+	return fs
+}
+
+func (p *Program) findCommand(name string) (Command, error) {
+	return findCommand(p.Commands, name, p.PrefixMatching)
 }
 
-func (p *Program) findCommand(name string) Command {
-	// Iterate over the commands in the program.
-	for _, command := range p.Commands {
-		if command.Name() == name {
-			return command
+// isCommand returns whether name names one of the program's commands, or is
+// ambiguous between them.
+func (p *Program) isCommand(name string) bool {
+	command, err := p.findCommand(name)
+	return command != nil || err != nil
+}
+
+// findCommand returns the command matching name, or nil if there is none.
+// A command matches if name is its name or one of its aliases, or if prefix is
+// true and name is a prefix of exactly one command that is not hidden.
+func findCommand(commands []Command, name string, prefix bool) (Command, error) {
+	// Iterate over the commands looking for an exact match.
+	for _, command := range commands {
+		if contains(commandNames(command), name) {
+			return command, nil
 		}
 	}
-	return nil
+
+	if !prefix || len(name) < 1 {
+		return nil, nil
+	}
+
+	// Iterate over the commands looking for a prefix match.
+	var candidates []Command
+	for _, command := range commands {
+		if command.Hidden() {
+			continue
+		}
+		for _, n := range commandNames(command) {
+			if strings.HasPrefix(n, name) {
+				candidates = append(candidates, command)
+				break
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, nil
+	case 1:
+		return candidates[0], nil
+	}
+
+	names := make([]string, 0, len(candidates))
+	for _, command := range candidates {
+		names = append(names, command.Name())
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("%s: ambiguous command, could be: %s", name, strings.Join(names, ", "))
+}
+
+// commandNames returns the name of the command followed by its aliases.
+func commandNames(command Command) []string {
+	names := []string{command.Name()}
+	if a, ok := command.(Aliaser); ok {
+		names = append(names, a.Aliases()...)
+	}
+	return names
 }
 
 func contains(match []string, a ...string) bool {
@@ -369,9 +1079,27 @@ func contains(match []string, a ...string) bool {
 	return false
 }
 
+// running returns the copy of p that is running with ctx, which also has the
+// commands added by default, or p if there is none.
+func (p *Program) running(ctx context.Context) *Program {
+	if r, ok := ctx.Value(programKey).(*Program); ok {
+		return r
+	}
+	return p
+}
+
 func (p *Program) defaultContext() context.Context {
+	return p.newContext(context.Background())
+}
+
+func (p *Program) newContext(parent context.Context) context.Context {
 	// Create the context with the values we need to pass to the version command.
-	ctx := context.WithValue(context.Background(), GitCommitKey, p.GitCommit)
+	ctx := context.WithValue(parent, GitCommitKey, p.GitCommit)
 	ctx = context.WithValue(ctx, NameKey, p.Name)
-	return context.WithValue(ctx, VersionKey, p.Version)
+	ctx = context.WithValue(ctx, VersionKey, p.Version)
+
+	// Add the streams so commands can write their output to them.
+	ctx = context.WithValue(ctx, StdinKey, p.stdin())
+	ctx = context.WithValue(ctx, StdoutKey, p.stdout())
+	return context.WithValue(ctx, StderrKey, p.stderr())
 }
@@ -7,9 +7,11 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -28,6 +30,11 @@ Show the error information.
 
 Show the version information.
 
+Flags:
+
+  --json   print the version information as JSON (default: false)
+  --short  print only the version (default: false)
+
 `
 )
 
@@ -116,6 +123,11 @@ Show the version information.
 
 Flags:
 
+  --json   print the version information as JSON (default: false)
+  --short  print only the version (default: false)
+
+Global flags:
+
   -d, --debug  enable debug logging (default: false)
   -o           where to save the output (default: defaultOutput)
   -t, --thing  a flag for thing (default: false)
@@ -137,6 +149,8 @@ Flags:
 	p.FlagSet.BoolVar(&debug, "t", false, "a flag for thing")
 	p.FlagSet.BoolVar(&debug, "d", false, "enable debug logging")
 	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	Alias(p.FlagSet, "thing", "t")
+	Alias(p.FlagSet, "debug", "d")
 
 	p.Commands = []Command{
 		&errorCommand{},
@@ -146,11 +160,11 @@ Flags:
 
 	p.Run()
 
+	// The usage should be printed once, with the version command.
 	c := startCapture(t)
-	if err := p.usage(p.defaultContext()); err != nil {
-		t.Fatal(err)
-	}
+	err := p.RunContext(context.Background(), []string{"sample", "--help"})
 	stdout, stderr := c.finish()
+	compareErrors(t, err, flag.ErrHelp)
 	if stderr != expectedOutput {
 		t.Fatalf("expected: %s\ngot: %s", expectedOutput, stderr)
 	}
@@ -160,9 +174,13 @@ Flags:
 
 	// Test versionCommand.
 	vcmd := &versionCommand{}
+	fs, err := p.commandFlagSet([]Command{vcmd}, p.FlagSet)
+	if err != nil {
+		t.Fatal(err)
+	}
 	c = startCapture(t)
-	p.resetCommandUsage(vcmd)
-	p.FlagSet.Usage()
+	p.resetCommandUsage([]Command{vcmd}, fs)
+	fs.Usage()
 	stdout, stderr = c.finish()
 	if stderr != expectedVersionOutput {
 		t.Fatalf("expected: %q\ngot: %q", expectedVersionOutput, stderr)
@@ -175,7 +193,7 @@ Flags:
 func TestProgramWithNoCommandsOrFlagsOrAction(t *testing.T) {
 	p := NewProgram()
 	p.Name = "yo"
-	testCases := append(testCasesEmpty(), testCasesUndefinedCommand()...)
+	testCases := append(testCasesEmpty(), testCasesNoCommands()...)
 
 	for _, tc := range testCases {
 		t.Run(tc.description, func(t *testing.T) {
@@ -324,6 +342,444 @@ func TestProgramWithCommands(t *testing.T) {
 	}
 }
 
+// Define the registryCommand, which has nested subcommands.
+type registryCommand struct {
+	host string
+	tags *tagsCommand
+}
+
+func (cmd *registryCommand) Name() string           { return "registry" }
+func (cmd *registryCommand) Args() string           { return "<command>" }
+func (cmd *registryCommand) ShortHelp() string      { return "Manage the registry." }
+func (cmd *registryCommand) LongHelp() string       { return "Manage the registry." }
+func (cmd *registryCommand) Hidden() bool           { return false }
+func (cmd *registryCommand) Subcommands() []Command { return []Command{cmd.tags} }
+func (cmd *registryCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.host, "host", "", "registry host")
+}
+func (cmd *registryCommand) Run(ctx context.Context, args []string) error {
+	return flag.ErrHelp
+}
+
+// Define the tagsCommand, a group with a single child.
+type tagsCommand struct {
+	list *listCommand
+}
+
+func (cmd *tagsCommand) Name() string                                 { return "tags" }
+func (cmd *tagsCommand) Args() string                                 { return "<command>" }
+func (cmd *tagsCommand) ShortHelp() string                            { return "Manage the tags." }
+func (cmd *tagsCommand) LongHelp() string                             { return "Manage the tags." }
+func (cmd *tagsCommand) Hidden() bool                                 { return false }
+func (cmd *tagsCommand) Subcommands() []Command                       { return []Command{cmd.list} }
+func (cmd *tagsCommand) Register(fs *flag.FlagSet)                    {}
+func (cmd *tagsCommand) Run(ctx context.Context, args []string) error { return flag.ErrHelp }
+
+// Define the listCommand, a leaf that records how it was run.
+type listCommand struct {
+	all  bool
+	ran  bool
+	args []string
+}
+
+func (cmd *listCommand) Name() string      { return "list" }
+func (cmd *listCommand) Args() string      { return "[repo...]" }
+func (cmd *listCommand) ShortHelp() string { return "List the tags." }
+func (cmd *listCommand) LongHelp() string  { return "List the tags." }
+func (cmd *listCommand) Hidden() bool      { return false }
+func (cmd *listCommand) Aliases() []string { return []string{"ls"} }
+func (cmd *listCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.all, "all", false, "list all tags")
+}
+func (cmd *listCommand) Run(ctx context.Context, args []string) error {
+	cmd.ran = true
+	cmd.args = args
+	return nil
+}
+
+func (cmd *listCommand) Complete(ctx context.Context, args []string, toComplete string) []string {
+	var candidates []string
+	for _, repo := range []string{"alpine", "busybox", "nginx"} {
+		if strings.HasPrefix(repo, toComplete) && !contains(args, repo) {
+			candidates = append(candidates, repo)
+		}
+	}
+	return candidates
+}
+
+func newRegistryCommand() *registryCommand {
+	return &registryCommand{tags: &tagsCommand{list: &listCommand{}}}
+}
+
+func TestProgramWithSubcommands(t *testing.T) {
+	registry := newRegistryCommand()
+	list := registry.tags.list
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.Commands = []Command{registry}
+
+	c := startCapture(t)
+	err := p.run(p.defaultContext(), []string{"yo", "registry", "-host", "r.j3ss.co", "tags", "list", "-all", "foo", "bar"})
+	c.finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !list.ran {
+		t.Fatal("expected list command to run")
+	}
+	if registry.host != "r.j3ss.co" {
+		t.Fatalf("expected host flag to be r.j3ss.co, got: %q", registry.host)
+	}
+	if !list.all {
+		t.Fatal("expected all flag to be true")
+	}
+	if strings.Join(list.args, " ") != "foo bar" {
+		t.Fatalf("expected args foo bar, got: %v", list.args)
+	}
+}
+
+func TestProgramHelpAfterArgs(t *testing.T) {
+	testCases := []struct {
+		args         []string
+		expectedErr  error
+		expectedArgs string
+	}{
+		{[]string{"yo", "registry", "tags", "list", "foo", "-h"}, flag.ErrHelp, ""},
+		{[]string{"yo", "registry", "tags", "list", "foo", "--help", "--", "bar"}, flag.ErrHelp, ""},
+		{[]string{"yo", "registry", "tags", "list", "foo", "--", "-h"}, nil, "foo -- -h"},
+		{[]string{"yo", "registry", "tags", "list", "--", "-h"}, nil, "-h"},
+		{[]string{"yo", "registry", "tags", "list", "--all", "--", "--help"}, nil, "--help"},
+		{[]string{"yo", "registry", "--host", "--", "tags", "list", "foo"}, nil, "foo"},
+	}
+
+	for _, gnu := range []bool{false, true} {
+		for _, tc := range testCases {
+			t.Run(fmt.Sprintf("gnu=%t/%s", gnu, strings.Join(tc.args, " ")), func(t *testing.T) {
+				registry := newRegistryCommand()
+				list := registry.tags.list
+
+				p := NewProgram()
+				p.Name = "yo"
+				p.GNUFlags = gnu
+				p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+				p.Commands = []Command{registry}
+
+				c := startCapture(t)
+				err := p.run(p.defaultContext(), tc.args)
+				c.finish()
+				compareErrors(t, err, tc.expectedErr)
+				if err != nil {
+					if list.ran {
+						t.Fatal("expected list command not to run")
+					}
+					return
+				}
+				if got := strings.Join(list.args, " "); got != tc.expectedArgs {
+					t.Fatalf("expected args %q, got: %q", tc.expectedArgs, got)
+				}
+			})
+		}
+	}
+}
+
+func TestProgramGlobalFlags(t *testing.T) {
+	testCases := []struct {
+		description  string
+		args         []string
+		gnu          bool
+		interspersed bool
+		action       bool
+		expectedErr  error
+		expected     string
+		expectedTags string
+	}{
+		{
+			description: "global flags before the command",
+			args:        []string{"yo", "-d", "registry", "-host", "r.j3ss.co", "tags", "list", "foo"},
+			expected:    "list foo",
+		},
+		{
+			description: "gnu global flags before the command",
+			args:        []string{"yo", "--debug", "registry", "tags", "ls", "--all", "foo"},
+			gnu:         true,
+			expected:    "list foo",
+		},
+		{
+			description: "global flags before an unknown command",
+			args:        []string{"yo", "-d", "nope"},
+			expectedErr: errors.New("nope: no such command"),
+		},
+		{
+			description: "global flags without a command",
+			args:        []string{"yo", "-d"},
+			expectedErr: flag.ErrHelp,
+		},
+		{
+			description: "command flags before the command",
+			args:        []string{"yo", "-all", "registry"},
+			expectedErr: errors.New("flag provided but not defined: -all"),
+		},
+		{
+			description: "global flags before the action arguments",
+			args:        []string{"yo", "-d", "foo", "bar"},
+			action:      true,
+			expected:    "action foo bar",
+		},
+		{
+			description: "global flags before a terminator",
+			args:        []string{"yo", "-d", "--", "-x"},
+			action:      true,
+			expected:    "action -x",
+		},
+		{
+			description:  "repeated global flags before the action arguments",
+			args:         []string{"yo", "-d", "-tag", "a", "-tag", "b", "foo"},
+			action:       true,
+			expected:     "action foo",
+			expectedTags: "a,b",
+		},
+		{
+			description:  "interspersed global flags around the action arguments",
+			args:         []string{"yo", "-d", "-tag", "a", "foo", "-tag", "b"},
+			interspersed: true,
+			action:       true,
+			expected:     "action foo",
+			expectedTags: "a,b",
+		},
+		{
+			description:  "interspersed global flags before the command",
+			args:         []string{"yo", "-d", "-tag", "a", "registry", "tags", "list", "foo", "-all"},
+			interspersed: true,
+			action:       true,
+			expected:     "list foo",
+			expectedTags: "a",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			var (
+				debug bool
+				tags  sliceValue
+				ran   string
+			)
+
+			registry := newRegistryCommand()
+			p := NewProgram()
+			p.Name = "yo"
+			p.Stderr = ioutil.Discard
+			p.GNUFlags = tc.gnu
+			p.InterspersedFlags = tc.interspersed
+			p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+			p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+			p.FlagSet.Var(&tags, "tag", "a tag, can be repeated")
+			Alias(p.FlagSet, "debug", "d")
+			p.Commands = []Command{registry}
+			if tc.action {
+				p.Action = func(ctx context.Context, args []string) error {
+					ran = strings.Join(append([]string{"action"}, args...), " ")
+					return nil
+				}
+			}
+
+			err := p.run(p.defaultContext(), tc.args)
+			compareErrors(t, err, tc.expectedErr)
+			if tc.expectedErr != nil {
+				return
+			}
+
+			if registry.tags.list.ran {
+				ran = strings.Join(append([]string{"list"}, registry.tags.list.args...), " ")
+			}
+			if ran != tc.expected {
+				t.Fatalf("expected %q to run, got: %q", tc.expected, ran)
+			}
+			if !debug {
+				t.Fatal("expected debug flag to be true")
+			}
+			// The flags should be parsed once.
+			if tags.String() != tc.expectedTags {
+				t.Fatalf("expected tags %q, got: %q", tc.expectedTags, tags.String())
+			}
+		})
+	}
+}
+
+// sliceValue is a flag value that can be repeated.
+type sliceValue []string
+
+func (v *sliceValue) String() string     { return strings.Join(*v, ",") }
+func (v *sliceValue) Set(s string) error { *v = append(*v, s); return nil }
+
+func TestProgramSubcommandUsage(t *testing.T) {
+	expected := `Usage: yo registry tags <command>
+
+Manage the tags.
+
+Flags:
+
+  --host  registry host (default: <none>)
+
+Global flags:
+
+  --debug  enable debug logging (default: false)
+
+Commands:
+
+  list, ls  List the tags.
+
+`
+
+	var debug bool
+	p := NewProgram()
+	p.Name = "yo"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	p.Commands = []Command{newRegistryCommand()}
+
+	c := startCapture(t)
+	err := p.run(p.defaultContext(), []string{"yo", "registry", "tags", "--help"})
+	_, stderr := c.finish()
+	compareErrors(t, err, flag.ErrHelp)
+	if stderr != expected {
+		t.Fatalf("expected: %q\ngot: %q", expected, stderr)
+	}
+}
+
+// Define the collideCommand, which registers a flag named like another one.
+type collideCommand struct {
+	name     string
+	flag     string
+	children []Command
+}
+
+func (cmd *collideCommand) Name() string           { return cmd.name }
+func (cmd *collideCommand) Args() string           { return "" }
+func (cmd *collideCommand) ShortHelp() string      { return "Collide." }
+func (cmd *collideCommand) LongHelp() string       { return "Collide." }
+func (cmd *collideCommand) Hidden() bool           { return false }
+func (cmd *collideCommand) Subcommands() []Command { return cmd.children }
+func (cmd *collideCommand) Register(fs *flag.FlagSet) {
+	fs.String(cmd.flag, "", "a colliding flag")
+}
+func (cmd *collideCommand) Run(ctx context.Context, args []string) error { return nil }
+
+func TestProgramCommandFlagSets(t *testing.T) {
+	var debug bool
+	registry := newRegistryCommand()
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.EnvPrefix = "YO"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	p.Commands = []Command{registry}
+
+	// The environment should not override the global flags set before the
+	// command name.
+	os.Setenv("YO_DEBUG", "false")
+	defer os.Unsetenv("YO_DEBUG")
+
+	if err := p.run(p.defaultContext(), []string{"yo", "-debug", "registry", "-host", "r.j3ss.co", "tags", "list", "-all"}); err != nil {
+		t.Fatal(err)
+	}
+	if !debug || registry.host != "r.j3ss.co" || !registry.tags.list.all {
+		t.Fatalf("expected all flags to be set, got debug=%t host=%q all=%t", debug, registry.host, registry.tags.list.all)
+	}
+
+	// The command flags should not be added to the global flags.
+	for _, name := range []string{"host", "all"} {
+		if p.FlagSet.Lookup(name) != nil {
+			t.Fatalf("expected flag %s not to be in the global flags", name)
+		}
+	}
+
+	// Flags named like a global flag or a flag of a parent command should
+	// return an error instead of panicking.
+	testCases := []struct {
+		commands    []Command
+		args        []string
+		expectedErr error
+	}{
+		{
+			commands:    []Command{&collideCommand{name: "collide", flag: "debug"}},
+			args:        []string{"yo", "collide"},
+			expectedErr: errors.New("collide: flag --debug is already defined as a global flag"),
+		},
+		{
+			commands:    []Command{&collideCommand{name: "registry", flag: "host", children: []Command{&collideCommand{name: "collide", flag: "host"}}}},
+			args:        []string{"yo", "registry", "collide"},
+			expectedErr: errors.New("registry collide: flag --host is already defined as a flag of a parent command"),
+		},
+	}
+
+	for _, tc := range testCases {
+		p.Commands = tc.commands
+		err := p.run(p.defaultContext(), tc.args)
+		compareErrors(t, err, tc.expectedErr)
+		if code := ExitCode(err); code != ExitFailure {
+			t.Fatalf("expected exit code %d, got: %d", ExitFailure, code)
+		}
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	commands := []Command{
+		&errorCommand{},
+		&testCommand{},
+		&ambiguousCommand{},
+		newRegistryCommand().tags.list,
+		&versionCommand{},
+	}
+
+	testCases := []struct {
+		name        string
+		prefix      bool
+		expected    string
+		expectedErr error
+	}{
+		{name: "test", expected: "test"},
+		{name: "ls", expected: "list"},
+		{name: "tes"},
+		{name: "nope"},
+		{name: "tes", prefix: true, expected: "test"},
+		{name: "l", prefix: true, expected: "list"},
+		{name: "v", prefix: true, expected: "version"},
+		{name: "nope", prefix: true},
+		{name: "", prefix: true},
+		{name: "te", prefix: true, expectedErr: errors.New("te: ambiguous command, could be: teapot, test")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s prefix=%t", tc.name, tc.prefix), func(t *testing.T) {
+			command, err := findCommand(commands, tc.name, tc.prefix)
+			compareErrors(t, err, tc.expectedErr)
+
+			var name string
+			if command != nil {
+				name = command.Name()
+			}
+			if name != tc.expected {
+				t.Fatalf("expected command %q, got: %q", tc.expected, name)
+			}
+		})
+	}
+}
+
+// Define the ambiguousCommand, which shares a prefix with the testCommand.
+type ambiguousCommand struct{}
+
+func (cmd *ambiguousCommand) Name() string                                 { return "teapot" }
+func (cmd *ambiguousCommand) Args() string                                 { return "" }
+func (cmd *ambiguousCommand) ShortHelp() string                            { return "I'm a teapot." }
+func (cmd *ambiguousCommand) LongHelp() string                             { return "I'm a teapot." }
+func (cmd *ambiguousCommand) Hidden() bool                                 { return false }
+func (cmd *ambiguousCommand) Register(fs *flag.FlagSet)                    {}
+func (cmd *ambiguousCommand) Run(ctx context.Context, args []string) error { return nil }
+
 func compareErrors(t *testing.T, err, expectedErr error) {
 	if expectedErr != nil {
 		if err == nil || err.Error() != expectedErr.Error() {
@@ -410,7 +866,7 @@ func (p *Program) isErrorOnAfter() bool {
 
 func (tc *testCase) expectUsageToBePrintedBeforeBefore(p *Program) bool {
 	return tc.args == nil || len(tc.args) < 1 ||
-		(p.Action == nil && len(p.Commands) > 1 && len(tc.args) < 2) ||
+		(p.Action == nil && len(p.Commands) > 0 && len(tc.args) < 2) ||
 		(tc.expectedErr != nil && strings.Contains(tc.expectedErr.Error(), "no such command"))
 }
 
@@ -455,3 +911,276 @@ func (p *Program) doTestRun(t *testing.T, tc testCase) {
 		compareErrors(t, err, errExpected)
 	}
 }
+
+func TestProgramRunContext(t *testing.T) {
+	var (
+		stdout, stderr bytes.Buffer
+		key            = ContextKey("test.key")
+		got            interface{}
+	)
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.Commands = []Command{&errorCommand{}}
+	p.Stdout = &stdout
+	p.Stderr = &stderr
+	p.Action = func(ctx context.Context, args []string) error {
+		got = ctx.Value(key)
+		return nil
+	}
+
+	// The caller's context should be passed through to the action.
+	ctx := context.WithValue(context.Background(), key, "value")
+	if err := p.RunContext(ctx, []string{"yo"}); err != nil {
+		t.Fatal(err)
+	}
+	if got != "value" {
+		t.Fatalf("expected context value %q, got: %v", "value", got)
+	}
+
+	// Errors from commands should be returned, not printed.
+	err := p.RunContext(ctx, []string{"yo", "error"})
+	compareErrors(t, err, errExpectedFromCommand)
+	if stderr.Len() > 0 {
+		t.Fatalf("expected no stderr, got: %s", stderr.String())
+	}
+
+	// Help should print the usage.
+	err = p.RunContext(ctx, []string{"yo", "--help"})
+	compareErrors(t, err, flag.ErrHelp)
+	if !strings.HasPrefix(stderr.String(), "yo -  A new command line program.") {
+		t.Fatalf("expected the usage to be printed, got: %q", stderr.String())
+	}
+}
+
+func TestProgramRunContextDefaultFlagSet(t *testing.T) {
+	testCases := []struct {
+		args         []string
+		expectedErr  error
+		expectedCode int
+	}{
+		{[]string{"yo", "-bogus", "registry"}, errors.New("flag provided but not defined: -bogus"), ExitUsage},
+		{[]string{"yo", "registry", "-bogus"}, errors.New("flag provided but not defined: -bogus"), ExitUsage},
+		{[]string{"yo", "registry", "tags", "list", "-bogus"}, errors.New("flag provided but not defined: -bogus"), ExitUsage},
+		{[]string{"yo", "registry", "-h"}, flag.ErrHelp, ExitSuccess},
+		{[]string{"yo", "registry", "tags", "list", "--help"}, flag.ErrHelp, ExitSuccess},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var stderr bytes.Buffer
+
+			// The default FlagSet should not make the flag package exit.
+			p := NewProgram()
+			p.Name = "yo"
+			p.Commands = []Command{newRegistryCommand()}
+			p.Stderr = &stderr
+
+			err := p.RunContext(context.Background(), tc.args)
+			compareErrors(t, err, tc.expectedErr)
+			if code := ExitCode(err); code != tc.expectedCode {
+				t.Fatalf("expected exit code %d, got: %d", tc.expectedCode, code)
+			}
+			if !strings.Contains(stderr.String(), "Usage: yo ") {
+				t.Fatalf("expected the usage to be printed, got: %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestProgramRepeatedRuns(t *testing.T) {
+	var (
+		stderr bytes.Buffer
+		host   string
+		called bool
+	)
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.Stderr = &stderr
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.StringVar(&host, "host", "", "registry host")
+	Required(p.FlagSet, "host")
+	p.FlagSet.Usage = func() { called = true }
+	p.Commands = []Command{&testCommand{}}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := p.RunContext(ctx, []string{"yo", "test", "-host", "r.j3ss.co"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Running the program should not modify it.
+	if len(p.Commands) != 1 {
+		t.Fatalf("expected 1 command, got: %d", len(p.Commands))
+	}
+	p.FlagSet.Usage()
+	if !called {
+		t.Fatal("expected the usage of the FlagSet not to be replaced")
+	}
+	if p.FlagSet.Parsed() {
+		t.Fatal("expected the FlagSet not to be parsed")
+	}
+
+	// The flags set in an earlier run should not count as set.
+	err := p.RunContext(ctx, []string{"yo", "test"})
+	compareErrors(t, err, errors.New("flag --host is required"))
+
+	// The usage should be printed once, even if the flag package printed it.
+	stderr.Reset()
+	err = p.RunContext(ctx, []string{"yo", "test", "-h"})
+	compareErrors(t, err, flag.ErrHelp)
+	if n := strings.Count(stderr.String(), "Usage: yo test"); n != 1 {
+		t.Fatalf("expected the usage to be printed once, got %d times: %q", n, stderr.String())
+	}
+}
+
+func TestProgramConcurrentRuns(t *testing.T) {
+	p := NewProgram()
+	p.Name = "yo"
+	p.Stdout = ioutil.Discard
+	p.Commands = []Command{&testCommand{}, &groupCommand{}}
+	p.Action = func(ctx context.Context, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected 1 argument, got: %v", args)
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			args := []string{"yo", fmt.Sprintf("arg%d", i)}
+			switch i % 3 {
+			case 1:
+				args = []string{"yo", "version"}
+			case 2:
+				args = []string{"yo", "group", "-host", "r.j3ss.co"}
+			}
+			errs <- p.RunContext(context.Background(), args)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Define the groupCommand, which groups the flags it registers.
+type groupCommand struct{}
+
+func (cmd *groupCommand) Name() string      { return "group" }
+func (cmd *groupCommand) Args() string      { return "" }
+func (cmd *groupCommand) ShortHelp() string { return "Group the flags." }
+func (cmd *groupCommand) LongHelp() string  { return "Group the flags." }
+func (cmd *groupCommand) Hidden() bool      { return false }
+func (cmd *groupCommand) Register(fs *flag.FlagSet) {
+	fs.String("host", "", "the registry host")
+	fs.String("output", "", "the output file")
+	Group(fs, "Registry flags", "host")
+	Group(fs, "Output flags", "output")
+}
+func (cmd *groupCommand) Run(ctx context.Context, args []string) error { return nil }
+
+// Define the hookCommand, which records the order its hooks are run in.
+type hookCommand struct {
+	name      string
+	events    *[]string
+	children  []Command
+	beforeErr error
+	runErr    error
+}
+
+func (cmd *hookCommand) Name() string              { return cmd.name }
+func (cmd *hookCommand) Args() string              { return "" }
+func (cmd *hookCommand) ShortHelp() string         { return "Record the hooks." }
+func (cmd *hookCommand) LongHelp() string          { return "Record the hooks." }
+func (cmd *hookCommand) Hidden() bool              { return false }
+func (cmd *hookCommand) Subcommands() []Command    { return cmd.children }
+func (cmd *hookCommand) Register(fs *flag.FlagSet) {}
+func (cmd *hookCommand) Before(ctx context.Context) error {
+	*cmd.events = append(*cmd.events, cmd.name+".Before")
+	return cmd.beforeErr
+}
+func (cmd *hookCommand) After(ctx context.Context) error {
+	*cmd.events = append(*cmd.events, cmd.name+".After")
+	return nil
+}
+func (cmd *hookCommand) Run(ctx context.Context, args []string) error {
+	*cmd.events = append(*cmd.events, cmd.name+".Run")
+	return cmd.runErr
+}
+
+func TestProgramHooks(t *testing.T) {
+	testCases := []struct {
+		description string
+		beforeErr   error
+		runErr      error
+		expected    []string
+	}{
+		{
+			description: "success",
+			expected: []string{
+				"Before", "parent.Before", "child.Before", "child.Run",
+				"child.After", "parent.After", "After", "Finally: <nil>",
+			},
+		},
+		{
+			description: "error on Run",
+			runErr:      errExpectedFromCommand,
+			expected: []string{
+				"Before", "parent.Before", "child.Before", "child.Run",
+				"child.After", "parent.After", "Finally: expected error command error",
+			},
+		},
+		{
+			description: "error on child Before",
+			beforeErr:   errExpected,
+			expected: []string{
+				"Before", "parent.Before", "child.Before",
+				"parent.After", "Finally: expected error",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			var events []string
+			record := func(name string) func(context.Context) error {
+				return func(ctx context.Context) error {
+					events = append(events, name)
+					return nil
+				}
+			}
+
+			child := &hookCommand{name: "child", events: &events, beforeErr: tc.beforeErr, runErr: tc.runErr}
+			parent := &hookCommand{name: "parent", events: &events, children: []Command{child}}
+
+			p := NewProgram()
+			p.Name = "yo"
+			p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+			p.Commands = []Command{parent}
+			p.Before = record("Before")
+			p.After = record("After")
+			p.Finally = func(ctx context.Context, err error) error {
+				events = append(events, fmt.Sprintf("Finally: %v", err))
+				return nil
+			}
+
+			p.run(p.defaultContext(), []string{"yo", "parent", "child"})
+
+			if strings.Join(events, ", ") != strings.Join(tc.expected, ", ") {
+				t.Fatalf("expected events: %v\ngot: %v", tc.expected, events)
+			}
+		})
+	}
+}
@@ -0,0 +1,372 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const completionHelp = `Print a completion script for the given shell.`
+
+func (cmd *completionCommand) Name() string      { return "completion" }
+func (cmd *completionCommand) Args() string      { return "<bash|zsh|fish>" }
+func (cmd *completionCommand) ShortHelp() string { return completionHelp }
+func (cmd *completionCommand) LongHelp() string {
+	return completionHelp + fmt.Sprintf(`
+
+To load the completions in the current shell, run:
+
+  bash: source <(%[1]s completion bash)
+  zsh:  source <(%[1]s completion zsh)
+  fish: %[1]s completion fish | source`, cmd.p.Name)
+}
+func (cmd *completionCommand) Hidden() bool { return true }
+
+func (cmd *completionCommand) Register(fs *flag.FlagSet) {}
+
+type completionCommand struct {
+	p *Program
+}
+
+func (cmd *completionCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError(flag.ErrHelp)
+	}
+
+	switch args[0] {
+	case "bash":
+		return cmd.p.writeBashCompletion(Stdout(ctx))
+	case "zsh":
+		return cmd.p.writeZshCompletion(Stdout(ctx))
+	case "fish":
+		return cmd.p.writeFishCompletion(Stdout(ctx))
+	}
+
+	return usageError(fmt.Errorf("%s: unsupported shell, must be one of: bash, zsh, fish", args[0]))
+}
+
+const completeHelp = `Print the candidates to complete the last argument with.`
+
+func (cmd *completeCommand) Name() string              { return "__complete" }
+func (cmd *completeCommand) Args() string              { return "-- [arg...] <partial>" }
+func (cmd *completeCommand) ShortHelp() string         { return completeHelp }
+func (cmd *completeCommand) LongHelp() string          { return completeHelp }
+func (cmd *completeCommand) Hidden() bool              { return true }
+func (cmd *completeCommand) Register(fs *flag.FlagSet) {}
+
+// completeCommand is the entry point the completion scripts call back into to
+// complete the arguments of commands implementing Completer.
+type completeCommand struct {
+	p *Program
+}
+
+func (cmd *completeCommand) Run(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return nil
+	}
+
+	for _, candidate := range cmd.p.complete(ctx, args[:len(args)-1], args[len(args)-1]) {
+		fmt.Fprintln(Stdout(ctx), candidate)
+	}
+	return nil
+}
+
+// complete returns the candidates for the partial word toComplete following
+// words on the command line, from the Completer of the command they name.
+func (p *Program) complete(ctx context.Context, words []string, toComplete string) []string {
+	// Flags are completed by the scripts.
+	if len(words) < 1 || strings.HasPrefix(toComplete, "-") {
+		return nil
+	}
+
+	// Parse the global flags before the command name.
+	if strings.HasPrefix(words[0], "-") {
+		rest, err := p.parseGlobalFlags(words)
+		if err != nil || len(rest) < 1 {
+			return nil
+		}
+		words = rest
+	}
+
+	command, err := p.findCommand(words[0])
+	if err != nil || command == nil {
+		return nil
+	}
+
+	// Walk down the tree of subcommands, parsing the flags along the way so
+	// the completer can use them. A -h among the arguments does not stop the
+	// completion.
+	path, fs, err := p.resolveCommand(command, words[1:])
+	if err != nil && err != flag.ErrHelp {
+		return nil
+	}
+
+	completer, ok := path[len(path)-1].(Completer)
+	if !ok {
+		return nil
+	}
+	return completer.Complete(ctx, fs.Args(), toComplete)
+}
+
+// completionNode holds what can be completed after the path of commands.
+type completionNode struct {
+	// path of command names from the top-level command, empty for the program.
+	path []string
+	// commands that can follow, mapping each name and alias to the command.
+	commands []completionCommandName
+	// flags that can follow, like "-d" and "--debug".
+	flags []string
+	// dynamic is whether the arguments are completed by calling back into
+	// the program, since the command implements Completer.
+	dynamic bool
+}
+
+type completionCommandName struct {
+	name      string
+	canonical string
+	help      string
+}
+
+// completionTree returns the nodes of the command tree of the program, with
+// the program itself first.
+func (p *Program) completionTree() []completionNode {
+	var globals []string
+	if p.FlagSet != nil {
+		globals = flagNames(p.FlagSet)
+	}
+	return completionNodes(nil, nil, p.Commands, globals)
+}
+
+func completionNodes(path []string, command Command, commands []Command, flags []string) []completionNode {
+	node := completionNode{
+		path:  path,
+		flags: flags,
+	}
+	if _, ok := command.(Completer); ok {
+		node.dynamic = true
+	}
+
+	var children []completionNode
+	for _, command := range commands {
+		if command.Hidden() {
+			continue
+		}
+
+		for _, name := range commandNames(command) {
+			node.commands = append(node.commands, completionCommandName{
+				name:      name,
+				canonical: command.Name(),
+				help:      command.ShortHelp(),
+			})
+		}
+
+		// Add the command's flags on top of the flags of its parents.
+		childFlags := append(append([]string{}, flags...), flagNames(commandFlags(command))...)
+
+		var grandchildren []Command
+		if parent, ok := command.(Subcommander); ok {
+			grandchildren = parent.Subcommands()
+		}
+
+		childPath := append(append([]string{}, path...), command.Name())
+		children = append(children, completionNodes(childPath, command, grandchildren, childFlags)...)
+	}
+
+	return append([]completionNode{node}, children...)
+}
+
+// flagNames returns the names of the flags in fs as they are typed on the
+// command line.
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, flagName(f.Name))
+	})
+	return names
+}
+
+// flagName returns a flag name as it is typed on the command line, with a
+// double dash for long names.
+func flagName(name string) string {
+	if len(name) > 1 {
+		return "--" + name
+	}
+	return "-" + name
+}
+
+// words returns the commands and flags that can follow the node.
+func (n completionNode) words() []string {
+	var words []string
+	for _, c := range n.commands {
+		words = append(words, c.name)
+	}
+	return append(words, n.flags...)
+}
+
+var nonIdentifier = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// completionFunc returns the name of the shell function for the program.
+func (p *Program) completionFunc() string {
+	return "_" + nonIdentifier.ReplaceAllString(p.Name, "_")
+}
+
+// shellQuote quotes s for bash and zsh.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func quoteAll(words []string, quote func(string) string) string {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		quoted = append(quoted, quote(w))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// writePathCases writes the cases of a shell case statement that move the
+// variable cmdpath down the command tree for each word on the command line.
+func writePathCases(w io.Writer, nodes []completionNode, format string) {
+	for _, n := range nodes {
+		for _, c := range n.commands {
+			from := strings.TrimSpace(strings.Join(n.path, " ") + " " + c.name)
+			to := strings.TrimSpace(strings.Join(n.path, " ") + " " + c.canonical)
+			fmt.Fprintf(w, format, shellQuote(from), shellQuote(to))
+		}
+	}
+}
+
+func (p *Program) writeBashCompletion(w io.Writer) error {
+	nodes := p.completionTree()
+	fn := p.completionFunc()
+
+	fmt.Fprintf(w, "# bash completion for %s\n\n", p.Name)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintln(w, `	local cur word cmdpath i words dynamic`)
+	fmt.Fprintln(w, `	cur="${COMP_WORDS[COMP_CWORD]}"`)
+	fmt.Fprintln(w, `	cmdpath=""`)
+	fmt.Fprintln(w, `	for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `		word="${COMP_WORDS[i]}"`)
+	fmt.Fprintln(w, `		case "${cmdpath:+${cmdpath} }${word}" in`)
+	writePathCases(w, nodes, "\t\t\t%s) cmdpath=%s ;;\n")
+	fmt.Fprintln(w, `		esac`)
+	fmt.Fprintln(w, `	done`)
+	fmt.Fprintln(w, `	case "${cmdpath}" in`)
+	for _, n := range nodes {
+		var dynamic string
+		if n.dynamic {
+			dynamic = " dynamic=1"
+		}
+		fmt.Fprintf(w, "\t\t%s) words=%s;%s ;;\n", shellQuote(strings.Join(n.path, " ")), shellQuote(strings.Join(n.words(), " ")), dynamic)
+	}
+	fmt.Fprintln(w, `	esac`)
+	fmt.Fprintln(w, `	if [ -n "${dynamic}" ]; then`)
+	fmt.Fprintln(w, `		words="${words} $("${COMP_WORDS[0]}" __complete -- "${COMP_WORDS[@]:1:COMP_CWORD-1}" "${cur}" 2>/dev/null)"`)
+	fmt.Fprintln(w, `	fi`)
+	fmt.Fprintln(w, `	COMPREPLY=($(compgen -W "${words}" -- "${cur}"))`)
+	fmt.Fprintln(w, `}`)
+	fmt.Fprintln(w)
+	_, err := fmt.Fprintf(w, "complete -F %s %s\n", fn, p.Name)
+	return err
+}
+
+func (p *Program) writeZshCompletion(w io.Writer) error {
+	nodes := p.completionTree()
+	fn := p.completionFunc()
+
+	fmt.Fprintf(w, "#compdef %s\n\n", p.Name)
+	fmt.Fprintf(w, "# zsh completion for %s\n\n", p.Name)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintln(w, `	local word cmdpath i dynamic`)
+	fmt.Fprintln(w, `	local -a candidates`)
+	fmt.Fprintln(w, `	cmdpath=""`)
+	fmt.Fprintln(w, `	for ((i = 2; i < CURRENT; i++)); do`)
+	fmt.Fprintln(w, `		word="${words[i]}"`)
+	fmt.Fprintln(w, `		case "${cmdpath:+${cmdpath} }${word}" in`)
+	writePathCases(w, nodes, "\t\t\t%s) cmdpath=%s ;;\n")
+	fmt.Fprintln(w, `		esac`)
+	fmt.Fprintln(w, `	done`)
+	fmt.Fprintln(w, `	case "${cmdpath}" in`)
+	for _, n := range nodes {
+		var dynamic string
+		if n.dynamic {
+			dynamic = " dynamic=1"
+		}
+		fmt.Fprintf(w, "\t\t%s) candidates=(%s);%s ;;\n", shellQuote(strings.Join(n.path, " ")), quoteAll(n.words(), shellQuote), dynamic)
+	}
+	fmt.Fprintln(w, `	esac`)
+	fmt.Fprintln(w, `	if [ -n "${dynamic}" ]; then`)
+	fmt.Fprintln(w, `		candidates+=(${(f)"$("${words[1]}" __complete -- "${(@)words[2,CURRENT-1]}" "${words[CURRENT]}" 2>/dev/null)"})`)
+	fmt.Fprintln(w, `	fi`)
+	fmt.Fprintln(w, `	compadd -- "${candidates[@]}"`)
+	fmt.Fprintln(w, `}`)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "if [ \"${funcstack[1]}\" = \"%s\" ]; then\n", fn)
+	fmt.Fprintf(w, "\t%s \"$@\"\n", fn)
+	fmt.Fprintln(w, `else`)
+	fmt.Fprintf(w, "\tcompdef %s %s\n", fn, p.Name)
+	_, err := fmt.Fprintln(w, `fi`)
+	return err
+}
+
+func (p *Program) writeFishCompletion(w io.Writer) error {
+	nodes := p.completionTree()
+	fn := p.completionFunc()
+
+	fmt.Fprintf(w, "# fish completion for %s\n\n", p.Name)
+	fmt.Fprintf(w, "function %s_path\n", fn)
+	fmt.Fprintln(w, `	set -l words (commandline -opc)`)
+	fmt.Fprintln(w, `	set -e words[1]`)
+	fmt.Fprintln(w, `	set -l cmdpath ""`)
+	fmt.Fprintln(w, `	for word in $words`)
+	fmt.Fprintln(w, `		switch (string trim -- "$cmdpath $word")`)
+	for _, n := range nodes {
+		for _, c := range n.commands {
+			from := strings.TrimSpace(strings.Join(n.path, " ") + " " + c.name)
+			to := strings.TrimSpace(strings.Join(n.path, " ") + " " + c.canonical)
+			fmt.Fprintf(w, "\t\t\tcase %s\n\t\t\t\tset cmdpath %s\n", fishQuote(from), fishQuote(to))
+		}
+	}
+	fmt.Fprintln(w, `		end`)
+	fmt.Fprintln(w, `	end`)
+	fmt.Fprintln(w, `	echo $cmdpath`)
+	fmt.Fprintln(w, `end`)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "function %s_candidates\n", fn)
+	fmt.Fprintf(w, "\tswitch (%s_path)\n", fn)
+	for _, n := range nodes {
+		fmt.Fprintf(w, "\t\tcase %s\n", fishQuote(strings.Join(n.path, " ")))
+
+		// Print the commands with their help as the description.
+		var candidates []string
+		for _, c := range n.commands {
+			candidates = append(candidates, c.name, c.help)
+		}
+		for _, f := range n.flags {
+			candidates = append(candidates, f, "")
+		}
+		if len(candidates) > 0 {
+			fmt.Fprintf(w, "\t\t\tprintf '%%s\\t%%s\\n' %s\n", quoteAll(candidates, fishQuote))
+		}
+
+		// Call back into the program to complete the arguments.
+		if n.dynamic {
+			fmt.Fprintln(w, `			set -l words (commandline -opc)`)
+			fmt.Fprintln(w, `			set -l cmd $words[1]`)
+			fmt.Fprintln(w, `			set -e words[1]`)
+			fmt.Fprintln(w, `			command $cmd __complete -- $words (commandline -ct) 2>/dev/null`)
+		}
+	}
+	fmt.Fprintln(w, `	end`)
+	fmt.Fprintln(w, `end`)
+	fmt.Fprintln(w)
+	_, err := fmt.Fprintf(w, "complete -c %s -f -a '(%s_candidates)'\n", p.Name, fn)
+	return err
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newCompletionProgram() *Program {
+	var debug bool
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "d", false, "enable debug logging")
+	p.Commands = []Command{
+		newRegistryCommand(),
+		&testCommand{},
+	}
+	return p
+}
+
+func TestCompletionTree(t *testing.T) {
+	p := newCompletionProgram()
+
+	expectedDynamic := map[string]bool{
+		"registry tags list": true,
+	}
+
+	expected := map[string]string{
+		"":                   "registry test -d",
+		"registry":           "tags -d --host",
+		"registry tags":      "list ls -d --host",
+		"registry tags list": "-d --host --all",
+		"test":               "-d",
+	}
+
+	nodes := p.completionTree()
+	if len(nodes) != len(expected) {
+		t.Fatalf("expected %d nodes, got: %d", len(expected), len(nodes))
+	}
+	for _, n := range nodes {
+		path := strings.Join(n.path, " ")
+		if words := strings.Join(n.words(), " "); words != expected[path] {
+			t.Fatalf("path %q: expected words %q, got: %q", path, expected[path], words)
+		}
+		if n.dynamic != expectedDynamic[path] {
+			t.Fatalf("path %q: expected dynamic to be %t", path, expectedDynamic[path])
+		}
+	}
+}
+
+func TestCompletionCommand(t *testing.T) {
+	testCases := []struct {
+		shell    string
+		contains string
+	}{
+		{"bash", "complete -F _yo yo"},
+		{"zsh", "compdef _yo yo"},
+		{"fish", "complete -c yo -f -a '(_yo_candidates)'"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.shell, func(t *testing.T) {
+			var stdout bytes.Buffer
+			p := newCompletionProgram()
+			p.Stdout = &stdout
+
+			if err := p.run(p.defaultContext(), []string{"yo", "completion", tc.shell}); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(stdout.String(), tc.contains) {
+				t.Fatalf("expected the script to contain %q, got:\n%s", tc.contains, stdout.String())
+			}
+		})
+	}
+
+	p := newCompletionProgram()
+	err := p.run(p.defaultContext(), []string{"yo", "completion", "tcsh"})
+	compareErrors(t, err, errors.New("tcsh: unsupported shell, must be one of: bash, zsh, fish"))
+}
+
+func TestProgramComplete(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"registry", "tags", "list", ""}, "alpine\nbusybox\nnginx\n"},
+		{[]string{"registry", "tags", "ls", "-all", "alpine", ""}, "busybox\nnginx\n"},
+		{[]string{"registry", "-host", "r.j3ss.co", "tags", "list", "b"}, "busybox\n"},
+		{[]string{"registry", "tags", "list", "-"}, ""},
+		{[]string{"-d", "registry", "tags", "list", ""}, "alpine\nbusybox\nnginx\n"},
+		{[]string{"test", ""}, ""},
+		{[]string{"nope", ""}, ""},
+		{[]string{""}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var stdout bytes.Buffer
+			p := newCompletionProgram()
+			p.Stdout = &stdout
+
+			args := append([]string{"yo", "__complete", "--"}, tc.args...)
+			if err := p.run(p.defaultContext(), args); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tc.expected {
+				t.Fatalf("expected: %q\ngot: %q", tc.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "cli-completion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var script bytes.Buffer
+	if err := newCompletionProgram().writeBashCompletion(&script); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "yo.bash")
+	if err := ioutil.WriteFile(file, script.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		words    string
+		expected string
+	}{
+		{`yo ""`, "registry test -d"},
+		{`yo re`, "registry"},
+		{`yo registry tags ""`, "list ls -d --host"},
+		{`yo registry tags ls --`, "--host --all"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.words, func(t *testing.T) {
+			cmd := exec.Command(bash, "-c", `source "$0" && COMP_WORDS=(`+tc.words+`) && COMP_CWORD=$((${#COMP_WORDS[@]} - 1)) && _yo && echo "${COMPREPLY[@]}"`, file)
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("running the completion failed: %v: %s", err, out)
+			}
+			if got := strings.TrimSpace(string(out)); got != tc.expected {
+				t.Fatalf("expected: %q\ngot: %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompletionCommandHelp(t *testing.T) {
+	cmd := &completionCommand{p: newCompletionProgram()}
+	if !strings.Contains(cmd.LongHelp(), "source <(yo completion bash)") {
+		t.Fatalf("expected the help to explain how to load the completions, got: %s", cmd.LongHelp())
+	}
+	if err := cmd.Run(context.Background(), nil); !isHelp(err) {
+		t.Fatalf("expected the usage to be printed without a shell, got: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// configFlag is the name of the flag holding the path of the config file.
+const configFlag = "config"
+
+// DefaultConfigFile returns the default path of the config file for the
+// program with the given name: $XDG_CONFIG_HOME/<name>/config.json, where
+// XDG_CONFIG_HOME defaults to $HOME/.config.
+func DefaultConfigFile(name string) string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, name, "config.json")
+}
+
+// registerConfigFlag adds the --config flag to fs, unless it already exists.
+func (p *Program) registerConfigFlag(fs *flag.FlagSet) {
+	if !p.LoadConfig || fs.Lookup(configFlag) != nil {
+		return
+	}
+
+	def := p.ConfigFile
+	if def == "" {
+		def = DefaultConfigFile(p.Name)
+	}
+	fs.String(configFlag, def, "path to the config file")
+}
+
+// setConfigFlagDoc replaces the default of the flag for the config file in fs,
+// if it is the default path on this machine, with a placeholder for the docs
+// of p, like "$XDG_CONFIG_HOME/yo/config.json".
+func setConfigFlagDoc(fs *flag.FlagSet, p *Program) {
+	f := fs.Lookup(configFlag)
+	if f == nil || p.ConfigFile != "" || f.DefValue != DefaultConfigFile(p.Name) {
+		return
+	}
+	f.DefValue = filepath.Join("$XDG_CONFIG_HOME", p.Name, "config.json")
+}
+
+// loadConfig reads the config file. A missing file is not an error, unless
+// its path was given on the command line.
+func (p *Program) loadConfig(fs *flag.FlagSet) (map[string]interface{}, string, error) {
+	f := fs.Lookup(configFlag)
+	if f == nil || f.Value.String() == "" {
+		return nil, "", nil
+	}
+	file := f.Value.String()
+
+	// Check if the path was set explicitly.
+	explicit := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == configFlag {
+			explicit = true
+		}
+	})
+
+	r, err := os.Open(file)
+	if os.IsNotExist(err) && !explicit {
+		return nil, file, nil
+	}
+	if err != nil {
+		return nil, file, err
+	}
+	defer r.Close()
+
+	var config map[string]interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&config); err != nil {
+		return nil, file, fmt.Errorf("parsing config file %s failed: %v", file, err)
+	}
+
+	return config, file, nil
+}
+
+// setFlagsFromConfig sets the flags in fs that are still unset from the config
+// file, if loading it is enabled. The section of the config file for the
+// command at the end of path is applied first, then the sections of its
+// parents, and finally the top-level values, which only set global flags.
+// Aliases are not set from the config file.
+func (p *Program) setFlagsFromConfig(fs *flag.FlagSet, path []Command) error {
+	if !p.LoadConfig {
+		return nil
+	}
+
+	config, file, err := p.loadConfig(fs)
+	if err != nil || config == nil {
+		return err
+	}
+
+	// Walk down the sections for the command path.
+	sections := []map[string]interface{}{config}
+	for _, command := range path {
+		section, ok := sections[len(sections)-1][command.Name()].(map[string]interface{})
+		if !ok {
+			break
+		}
+		sections = append(sections, section)
+	}
+
+	// Get the flags that were set on the command line or from the environment.
+	set := setFlags(fs)
+
+	// Apply the most specific section first.
+	for i := len(sections) - 1; i >= 0; i-- {
+		for name, value := range sections[i] {
+			if f := fs.Lookup(name); set[name] || f == nil || isAlias(f) {
+				continue
+			}
+			// The flags of the commands are only set from their sections.
+			if i == 0 && p.FlagSet.Lookup(name) == nil {
+				continue
+			}
+
+			for _, v := range configValues(value) {
+				if err := fs.Set(name, v); err != nil {
+					return fmt.Errorf("invalid value %q for %q in %s: %v", v, name, file, err)
+				}
+			}
+			set[name] = true
+		}
+	}
+
+	return nil
+}
+
+// configValues returns the values to set a flag to for a value from the config
+// file. Arrays set the flag once for each element, for flags that can be
+// repeated.
+func configValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case json.Number:
+		return []string{v.String()}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case []interface{}:
+		var values []string
+		for _, e := range v {
+			values = append(values, configValues(e)...)
+		}
+		return values
+	}
+
+	// Ignore nulls and sections.
+	return nil
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultConfigFile(t *testing.T) {
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	defer os.Setenv("XDG_CONFIG_HOME", xdg)
+
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if file := DefaultConfigFile("yo"); file != filepath.Join("/xdg", "yo", "config.json") {
+		t.Fatalf("expected the config file in XDG_CONFIG_HOME, got: %s", file)
+	}
+
+	os.Setenv("XDG_CONFIG_HOME", "")
+	expected := filepath.Join(os.Getenv("HOME"), ".config", "yo", "config.json")
+	if file := DefaultConfigFile("yo"); file != expected {
+		t.Fatalf("expected: %s\ngot: %s", expected, file)
+	}
+}
+
+func TestProgramConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(file, []byte(`{
+	"debug": true,
+	"token": "from-config",
+	"output": "from-config",
+	"registry": {
+		"host": "r.j3ss.co",
+		"tags": {
+			"list": {"all": true}
+		}
+	}
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("YOTEST_OUTPUT", "from-env")
+	defer os.Unsetenv("YOTEST_OUTPUT")
+
+	var (
+		debug  bool
+		token  string
+		output string
+		level  string
+	)
+
+	registry := newRegistryCommand()
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.LoadConfig = true
+	p.ConfigFile = file
+	p.EnvPrefix = "YOTEST"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	p.FlagSet.StringVar(&token, "token", "", "API token")
+	p.FlagSet.StringVar(&output, "output", "", "where to save the output")
+	p.FlagSet.StringVar(&level, "level", "info", "log level")
+	p.Commands = []Command{registry}
+
+	if err := p.run(p.defaultContext(), []string{"yo", "registry", "-token", "from-flag", "tags", "list"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !debug {
+		t.Fatal("expected debug to be set from the config file")
+	}
+	if token != "from-flag" {
+		t.Fatalf("expected token from the command line, got: %q", token)
+	}
+	if output != "from-env" {
+		t.Fatalf("expected output from the environment, got: %q", output)
+	}
+	if level != "info" {
+		t.Fatalf("expected level to be the default, got: %q", level)
+	}
+	if registry.host != "r.j3ss.co" {
+		t.Fatalf("expected host from the registry section, got: %q", registry.host)
+	}
+	if !registry.tags.list.all {
+		t.Fatal("expected all from the list section")
+	}
+}
+
+func TestProgramConfigCommandFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(file, []byte(`{"all": true, "host": "r.j3ss.co"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := newRegistryCommand()
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.LoadConfig = true
+	p.ConfigFile = file
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.Commands = []Command{registry}
+
+	if err := p.run(p.defaultContext(), []string{"yo", "registry", "tags", "list"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The top-level values should only set global flags.
+	if registry.host != "" {
+		t.Fatalf("expected host not to be set from the top level, got: %q", registry.host)
+	}
+	if registry.tags.list.all {
+		t.Fatal("expected all not to be set from the top level")
+	}
+}
+
+func TestProgramConfigAlias(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(file, []byte(`{"debug": false, "o": "from-alias-config"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		debug  bool
+		output string
+	)
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.LoadConfig = true
+	p.ConfigFile = file
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "d", false, "enable debug logging")
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	p.FlagSet.StringVar(&output, "output", "default", "where to save the output")
+	Alias(p.FlagSet, "debug", "d")
+	Alias(p.FlagSet, "output", "o")
+	p.Commands = []Command{newRegistryCommand()}
+
+	if err := p.run(p.defaultContext(), []string{"yo", "-d", "registry", "tags", "list"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A flag set through its alias should win over the config file, and the
+	// aliases should not be set from keys of their own.
+	if !debug {
+		t.Fatal("expected debug to be set from the command line")
+	}
+	if output != "default" {
+		t.Fatalf("expected output to be the default, got: %q", output)
+	}
+}
+
+func TestProgramConfigMissing(t *testing.T) {
+	p := NewProgram()
+	p.Name = "yo"
+	p.LoadConfig = true
+	p.ConfigFile = filepath.Join(os.TempDir(), "cli-config-does-not-exist.json")
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.Action = nilActionFunction
+
+	// A missing default config file is fine.
+	if err := p.run(p.defaultContext(), []string{"yo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing config file given on the command line is not.
+	err := p.run(p.defaultContext(), []string{"yo", "-config", p.ConfigFile})
+	if code := ExitCode(err); code != ExitUsage {
+		t.Fatalf("expected exit code %d, got: %d (%v)", ExitUsage, code, err)
+	}
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envName returns the name of the environment variable for the flag name,
+// like REG_SKIP_PING for the flag "skip-ping" with the prefix "REG".
+func envName(prefix, name string) string {
+	if !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	name = strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	return strings.ToUpper(prefix + name)
+}
+
+// setFlagsFromEnv sets the flags in fs that were not set on the command line
+// from their environment variables, if the program has an EnvPrefix. Aliases
+// have no variables of their own.
+func (p *Program) setFlagsFromEnv(fs *flag.FlagSet) error {
+	if p.EnvPrefix == "" {
+		return nil
+	}
+
+	// Get the flags that were set on the command line.
+	set := setFlags(fs)
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] || isAlias(f) {
+			return
+		}
+
+		name := envName(p.EnvPrefix, f.Name)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+
+		if serr := fs.Set(f.Name, v); serr != nil {
+			err = fmt.Errorf("invalid value %q for $%s: %v", v, name, serr)
+		}
+	})
+	return err
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	testCases := []struct {
+		prefix, name, expected string
+	}{
+		{"REG_", "debug", "REG_DEBUG"},
+		{"REG", "debug", "REG_DEBUG"},
+		{"reg_", "skip-ping", "REG_SKIP_PING"},
+		{"REG_", "auth.url", "REG_AUTH_URL"},
+	}
+
+	for _, tc := range testCases {
+		if name := envName(tc.prefix, tc.name); name != tc.expected {
+			t.Fatalf("envName(%q, %q): expected %q, got: %q", tc.prefix, tc.name, tc.expected, name)
+		}
+	}
+}
+
+func TestProgramEnv(t *testing.T) {
+	var (
+		debug bool
+		token string
+	)
+
+	os.Setenv("YOTEST_DEBUG", "true")
+	os.Setenv("YOTEST_TOKEN", "from-env")
+	os.Setenv("YOTEST_HOST", "r.j3ss.co")
+	defer os.Unsetenv("YOTEST_DEBUG")
+	defer os.Unsetenv("YOTEST_TOKEN")
+	defer os.Unsetenv("YOTEST_HOST")
+
+	registry := newRegistryCommand()
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.EnvPrefix = "YOTEST_"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	p.FlagSet.StringVar(&token, "token", "", "API token")
+	p.Commands = []Command{registry}
+
+	// Flags on the command line win over the environment.
+	if err := p.run(p.defaultContext(), []string{"yo", "registry", "-token", "from-flag", "tags", "list"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !debug {
+		t.Fatal("expected debug to be set from the environment")
+	}
+	if token != "from-flag" {
+		t.Fatalf("expected token from the command line, got: %q", token)
+	}
+	if registry.host != "r.j3ss.co" {
+		t.Fatalf("expected the command's host flag to be set from the environment, got: %q", registry.host)
+	}
+}
+
+func TestProgramEnvAlias(t *testing.T) {
+	os.Setenv("YOTEST_DEBUG", "false")
+	os.Setenv("YOTEST_D", "true")
+	os.Setenv("YOTEST_OUTPUT", "from-env")
+	os.Setenv("YOTEST_O", "from-alias-env")
+	defer os.Unsetenv("YOTEST_DEBUG")
+	defer os.Unsetenv("YOTEST_D")
+	defer os.Unsetenv("YOTEST_OUTPUT")
+	defer os.Unsetenv("YOTEST_O")
+
+	testCases := []struct {
+		args           []string
+		expectedDebug  bool
+		expectedOutput string
+	}{
+		{[]string{"yo", "registry", "tags", "list"}, false, "from-env"},
+		{[]string{"yo", "-d", "registry", "tags", "list"}, true, "from-env"},
+		{[]string{"yo", "registry", "-o", "from-flag", "tags", "list"}, false, "from-flag"},
+		{[]string{"yo", "registry", "tags", "list", "-d", "-o", "from-flag"}, true, "from-flag"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var (
+				debug  bool
+				output string
+			)
+
+			p := NewProgram()
+			p.Name = "yo"
+			p.EnvPrefix = "YOTEST"
+			p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+			p.FlagSet.BoolVar(&debug, "d", false, "enable debug logging")
+			p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+			p.FlagSet.StringVar(&output, "output", "", "where to save the output")
+			Alias(p.FlagSet, "debug", "d")
+			Alias(p.FlagSet, "output", "o")
+			p.Commands = []Command{newRegistryCommand()}
+
+			// A flag set through its alias should win over the environment,
+			// and the aliases should not be set from variables of their own.
+			if err := p.run(p.defaultContext(), tc.args); err != nil {
+				t.Fatal(err)
+			}
+			if debug != tc.expectedDebug {
+				t.Fatalf("expected debug to be %t, got: %t", tc.expectedDebug, debug)
+			}
+			if output != tc.expectedOutput {
+				t.Fatalf("expected output %q, got: %q", tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestProgramEnvInvalid(t *testing.T) {
+	var debug bool
+
+	os.Setenv("YOTEST_DEBUG", "nope")
+	defer os.Unsetenv("YOTEST_DEBUG")
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.EnvPrefix = "YOTEST"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	p.Action = nilActionFunction
+
+	err := p.run(p.defaultContext(), []string{"yo"})
+	compareErrors(t, err, errors.New(`invalid value "nope" for $YOTEST_DEBUG: parse error`))
+	if code := ExitCode(err); code != ExitUsage {
+		t.Fatalf("expected exit code %d, got: %d", ExitUsage, code)
+	}
+}
+
+func TestProgramEnvUsage(t *testing.T) {
+	var (
+		stderr bytes.Buffer
+		debug  bool
+
+		expected = `Usage: yo test` + " " + `
+
+Show the test information.
+
+Global flags:
+
+  -d, --debug  enable debug logging (default: false) [$YO_DEBUG]
+
+`
+	)
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.EnvPrefix = "YO_"
+	p.Stderr = &stderr
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "d", false, "enable debug logging")
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	Alias(p.FlagSet, "debug", "d")
+	p.Commands = []Command{&testCommand{}}
+
+	err := p.run(p.defaultContext(), []string{"yo", "test", "-h"})
+	compareErrors(t, err, flag.ErrHelp)
+	if stderr.String() != expected {
+		t.Fatalf("expected: %q\ngot: %q", expected, stderr.String())
+	}
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+const (
+	// ExitSuccess is the exit code for a program that ran successfully,
+	// including when the usage was explicitly requested with --help.
+	ExitSuccess = 0
+	// ExitFailure is the exit code for a program that failed.
+	ExitFailure = 1
+	// ExitUsage is the exit code for a program that was invoked incorrectly,
+	// like with an unknown command or invalid flags.
+	ExitUsage = 2
+)
+
+// ExitCoder is the interface implemented by errors that carry the code the
+// program should exit with. Commands can return an ExitCoder from Run to
+// choose the exit code.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// ExitError is an error that carries the code the program should exit with.
+type ExitError struct {
+	Err  error
+	Code int
+}
+
+// NewExitError returns an error that makes the program print err and exit
+// with code.
+func NewExitError(err error, code int) error {
+	return &ExitError{Err: err, Code: code}
+}
+
+// Error returns the message of the underlying error.
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+// ExitCode returns the code the program should exit with.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Unwrap returns the underlying error.
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the code a program should exit with after returning err.
+// A nil error and flag.ErrHelp exit with ExitSuccess, an ExitCoder exits with
+// the code it carries, and any other error exits with ExitFailure. Wrapped
+// errors are unwrapped to find them.
+func ExitCode(err error) int {
+	var e ExitCoder
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.As(err, &e):
+		return e.ExitCode()
+	case errors.Is(err, flag.ErrHelp):
+		return ExitSuccess
+	}
+	return ExitFailure
+}
+
+// usageError returns err with the ExitUsage exit code.
+func usageError(err error) error {
+	return &ExitError{Err: err, Code: ExitUsage}
+}
+
+// flagError returns the error for err returned from parsing flags. Explicit
+// requests for help are returned as is, other errors are usage errors.
+func flagError(err error) error {
+	if err == nil || err == flag.ErrHelp {
+		return err
+	}
+	return usageError(err)
+}
+
+// isHelp returns whether err means the usage should be printed.
+func isHelp(err error) bool {
+	return errors.Is(err, flag.ErrHelp)
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+var errNotFound = NewExitError(errors.New("not found"), 3)
+
+// Define the notFoundCommand, which returns an error with an exit code.
+type notFoundCommand struct{}
+
+func (cmd *notFoundCommand) Name() string                                 { return "notfound" }
+func (cmd *notFoundCommand) Args() string                                 { return "" }
+func (cmd *notFoundCommand) ShortHelp() string                            { return "Find nothing." }
+func (cmd *notFoundCommand) LongHelp() string                             { return "Find nothing." }
+func (cmd *notFoundCommand) Hidden() bool                                 { return false }
+func (cmd *notFoundCommand) Register(fs *flag.FlagSet)                    {}
+func (cmd *notFoundCommand) Run(ctx context.Context, args []string) error { return errNotFound }
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected int
+	}{
+		{nil, ExitSuccess},
+		{flag.ErrHelp, ExitSuccess},
+		{errExpected, ExitFailure},
+		{usageError(flag.ErrHelp), ExitUsage},
+		{errNotFound, 3},
+		{NewExitError(nil, 4), 4},
+		{fmt.Errorf("notfound: %w", errNotFound), 3},
+		{fmt.Errorf("run: %w", flag.ErrHelp), ExitSuccess},
+		{fmt.Errorf("run: %w", usageError(errExpected)), ExitUsage},
+	}
+
+	for _, tc := range testCases {
+		if code := ExitCode(tc.err); code != tc.expected {
+			t.Fatalf("ExitCode(%v): expected %d, got: %d", tc.err, tc.expected, code)
+		}
+	}
+}
+
+func TestIsHelp(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{errExpected, false},
+		{flag.ErrHelp, true},
+		{usageError(flag.ErrHelp), true},
+		{fmt.Errorf("run: %w", flag.ErrHelp), true},
+		{usageError(errExpected), false},
+	}
+
+	for _, tc := range testCases {
+		if got := isHelp(tc.err); got != tc.expected {
+			t.Fatalf("isHelp(%v): expected %t, got: %t", tc.err, tc.expected, got)
+		}
+	}
+}
+
+func TestProgramExitCodes(t *testing.T) {
+	p := NewProgram()
+	p.Name = "yo"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.Commands = []Command{
+		&errorCommand{},
+		&notFoundCommand{},
+		&testCommand{},
+	}
+	p.Stderr = ioutil.Discard
+
+	testCases := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"yo"}, ExitUsage},
+		{[]string{"yo", "--help"}, ExitSuccess},
+		{[]string{"yo", "help"}, ExitSuccess},
+		{[]string{"yo", "nope"}, ExitUsage},
+		{[]string{"yo", "test"}, ExitSuccess},
+		{[]string{"yo", "test", "-h"}, ExitSuccess},
+		{[]string{"yo", "test", "-nope"}, ExitUsage},
+		{[]string{"yo", "error"}, ExitFailure},
+		{[]string{"yo", "notfound"}, 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("args: %v", tc.args), func(t *testing.T) {
+			err := p.RunContext(context.Background(), tc.args)
+			if code := ExitCode(err); code != tc.expected {
+				t.Fatalf("expected exit code %d, got: %d (%v)", tc.expected, code, err)
+			}
+		})
+	}
+}
@@ -4,10 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
 	"os"
-	"os/signal"
-	"syscall"
 	"testing"
 
 	"github.com/genuinetools/pkg/cli"
@@ -60,16 +57,13 @@ func ExampleNewProgram_withSingleAction() {
 
 	// Set the main program action.
 	p.Action = func(ctx context.Context, args []string) error {
-		// On ^C, or SIGTERM handle exit.
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
-		signal.Notify(c, syscall.SIGTERM)
-		go func() {
-			for sig := range c {
-				log.Printf("Received %s, exiting.", sig.String())
-				os.Exit(0)
-			}
-		}()
+		// The context is canceled on ^C or SIGTERM, so long running work
+		// should stop once ctx.Done() is closed.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
 
 		fmt.Fprintln(os.Stdout, "yo")
 		return nil
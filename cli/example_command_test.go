@@ -4,7 +4,6 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"os"
 
 	"github.com/genuinetools/pkg/cli"
 )
@@ -22,7 +21,8 @@ func (cmd *yoCommand) Register(fs *flag.FlagSet) {}
 type yoCommand struct{}
 
 func (cmd *yoCommand) Run(ctx context.Context, args []string) error {
-	fmt.Fprintln(os.Stdout, "yo")
+	// Write to the program's output stream.
+	fmt.Fprintln(cli.Stdout(ctx), "yo")
 	return nil
 }
 
@@ -0,0 +1,384 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Alias declares each of the aliases as another name for the flag name in fs,
+// like "d" for "debug", so the usage output shows them together, like
+// "-d, --debug". Aliases that are not defined yet are defined to set the flag.
+// Aliases that are already defined, like with a second BoolVar for the same
+// variable, are only marked as aliases. Alias panics if name is not defined.
+func Alias(fs *flag.FlagSet, name string, aliases ...string) {
+	f := lookupDefined(fs, name)
+
+	for _, alias := range aliases {
+		if a := fs.Lookup(alias); a != nil {
+			meta(a).alias = name
+			continue
+		}
+		fs.Var(&metaValue{Value: f.Value, alias: name}, alias, f.Usage)
+	}
+}
+
+// Group puts the flags in fs with the given names in a section with title in
+// the usage output, like "Registry flags". The flags that are not in a group
+// are shown first, then the groups in the order they were declared. Group
+// panics if one of the flags is not defined.
+func Group(fs *flag.FlagSet, title string, names ...string) {
+	order := groupOrder(fs, title)
+	for _, name := range names {
+		m := meta(lookupDefined(fs, name))
+		m.group = title
+		m.groupOrder = order
+	}
+}
+
+// groupOrder returns the position of the group with title among the groups
+// declared in fs, which is after the others if it is new.
+func groupOrder(fs *flag.FlagSet, title string) int {
+	var last, order int
+	fs.VisitAll(func(f *flag.Flag) {
+		m := flagMeta(f)
+		if m.groupOrder > last {
+			last = m.groupOrder
+		}
+		if m.group == title {
+			order = m.groupOrder
+		}
+	})
+	if order == 0 {
+		order = last + 1
+	}
+	return order
+}
+
+// setFlags returns the names of the flags in fs that are set, counting a flag
+// as set when one of its aliases is.
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+		if alias := flagMeta(f).alias; alias != "" {
+			set[alias] = true
+		}
+	})
+	return set
+}
+
+// isAlias returns whether f is an alias of another flag.
+func isAlias(f *flag.Flag) bool {
+	return flagMeta(f).alias != ""
+}
+
+// inheritFlags defines the flags of parent in fs, sharing their values. The
+// flags that were set in parent are set in fs as well, without setting their
+// values again, so they count as set when parsing continues with fs.
+func inheritFlags(fs, parent *flag.FlagSet) {
+	set := map[string]bool{}
+	parent.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	parent.VisitAll(func(f *flag.Flag) {
+		fs.Var(inheritedValue{f.Value}, f.Name, f.Usage)
+		if set[f.Name] {
+			fs.Set(f.Name, "")
+		}
+
+		// Swap in the value once the flag is marked as set.
+		g := fs.Lookup(f.Name)
+		g.Value = f.Value
+		g.DefValue = f.DefValue
+	})
+}
+
+// inheritedValue wraps the value of an inherited flag so marking the flag as
+// set does not set the value.
+type inheritedValue struct {
+	flag.Value
+}
+
+// Set does nothing, since the value was already set.
+func (v inheritedValue) Set(string) error { return nil }
+
+// lookupDefined returns the flag name in fs, and panics if it is not defined.
+func lookupDefined(fs *flag.FlagSet, name string) *flag.Flag {
+	f := fs.Lookup(name)
+	if f == nil {
+		panic(fmt.Sprintf("flag %s is not defined", flagName(name)))
+	}
+	return f
+}
+
+// metaValue wraps the flag.Value of a flag to hold the metadata declared with
+// the functions of this package.
+type metaValue struct {
+	flag.Value
+
+	// alias is the name of the flag this flag is an alias of, if any.
+	alias string
+	// group is the title of the group of the flag, if any.
+	group      string
+	groupOrder int
+
+	// required is whether the flag must be set.
+	required bool
+	// allowed are the values the flag can be set to, if restricted.
+	allowed []string
+	// constraints are the constraints on the groups the flag is the first
+	// flag of.
+	constraints []*flagConstraint
+}
+
+// meta returns the metadata of f, wrapping its value if needed.
+func meta(f *flag.Flag) *metaValue {
+	if m, ok := f.Value.(*metaValue); ok {
+		return m
+	}
+	m := &metaValue{Value: f.Value}
+	f.Value = m
+	return m
+}
+
+// flagMeta returns the metadata of f, which is empty if none was declared.
+func flagMeta(f *flag.Flag) metaValue {
+	if m, ok := f.Value.(*metaValue); ok {
+		return *m
+	}
+	return metaValue{Value: f.Value}
+}
+
+// String returns the value of the flag.
+func (v *metaValue) String() string {
+	// The flag package calls String on the zero value to find the default.
+	if v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+// IsBoolFlag returns whether the flag is a boolean flag.
+func (v *metaValue) IsBoolFlag() bool {
+	return isBoolFlag(v.Value)
+}
+
+// Get returns the value of the flag.
+func (v *metaValue) Get() interface{} {
+	if g, ok := v.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+	return v.String()
+}
+
+// boolFlag is the interface the flag package uses for flags that do not need
+// an argument.
+type boolFlag interface {
+	IsBoolFlag() bool
+}
+
+func isBoolFlag(v flag.Value) bool {
+	b, ok := v.(boolFlag)
+	return ok && b.IsBoolFlag()
+}
+
+// parseGNU parses args into fs POSIX/GNU style. See Program.GNUFlags.
+// Errors are handled according to the ErrorHandling of fs, like the flag
+// package does.
+func parseGNU(fs *flag.FlagSet, args []string) error {
+	positionals, err := parseGNUArgs(fs, args)
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(fs.Output(), err)
+		}
+		if fs.Usage != nil {
+			fs.Usage()
+		} else {
+			fs.PrintDefaults()
+		}
+
+		switch fs.ErrorHandling() {
+		case flag.ContinueOnError:
+			return err
+		case flag.ExitOnError:
+			if err == flag.ErrHelp {
+				os.Exit(0)
+			}
+			os.Exit(2)
+		case flag.PanicOnError:
+			panic(err)
+		}
+		return err
+	}
+
+	// Let the FlagSet hold the positional arguments, so they are returned by
+	// its Args method.
+	return fs.Parse(append([]string{"--"}, positionals...))
+}
+
+// parseGNUArgs sets the flags in args and returns the positional arguments.
+func parseGNUArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	for len(args) > 0 {
+		arg := args[0]
+
+		// Stop at the terminator or the first positional argument.
+		if arg == "--" {
+			return args[1:], nil
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			return args, nil
+		}
+		args = args[1:]
+
+		// Parse a long flag, like --output=file or --output file.
+		if strings.HasPrefix(arg, "--") {
+			name, value := arg[2:], ""
+			hasValue := false
+			if i := strings.Index(name, "="); i >= 0 {
+				name, value, hasValue = name[:i], name[i+1:], true
+			}
+
+			f, err := lookupFlag(fs, name)
+			if err != nil {
+				return nil, err
+			}
+
+			if !hasValue && !isBoolFlag(f.Value) {
+				if len(args) < 1 {
+					return nil, fmt.Errorf("flag needs an argument: %s", flagName(name))
+				}
+				value, args, hasValue = args[0], args[1:], true
+			}
+			if !hasValue {
+				value = "true"
+			}
+
+			if err := setFlag(fs, f, value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Parse bundled short flags, like -abc, -ofile or -o file.
+		shorts := arg[1:]
+		for len(shorts) > 0 {
+			name := shorts[:1]
+			shorts = shorts[1:]
+
+			f, err := lookupFlag(fs, name)
+			if err != nil {
+				return nil, err
+			}
+
+			if isBoolFlag(f.Value) {
+				if err := setFlag(fs, f, "true"); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			// The rest of the bundle is the value, otherwise the next argument.
+			value := strings.TrimPrefix(shorts, "=")
+			if shorts == "" {
+				if len(args) < 1 {
+					return nil, fmt.Errorf("flag needs an argument: %s", flagName(name))
+				}
+				value, args = args[0], args[1:]
+			}
+			shorts = ""
+
+			if err := setFlag(fs, f, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// parseInterspersed parses args into fs with parse, allowing flags after the
+// positional arguments. It stops at a "--" or at a first positional argument
+// for which isCommand returns true, leaving the rest as positional arguments.
+// The gnu argument is whether parse bundles short flags, like parseGNU. See
+// Program.InterspersedFlags.
+func parseInterspersed(fs *flag.FlagSet, args []string, gnu bool, parse func([]string) error, isCommand func(string) bool) error {
+	var positionals []string
+	for {
+		if err := parse(args); err != nil {
+			return err
+		}
+
+		// Both parsers stop at the first positional argument, or after a "--".
+		rest := fs.Args()
+		parsed := args[:len(args)-len(rest)]
+		if len(rest) < 1 || terminated(fs, parsed, gnu) || (len(positionals) < 1 && isCommand(rest[0])) {
+			positionals = append(positionals, rest...)
+			break
+		}
+
+		// Keep the positional argument and parse the flags after it.
+		positionals = append(positionals, rest[0])
+		args = rest[1:]
+	}
+
+	// Let the FlagSet hold all the positional arguments.
+	return fs.Parse(append([]string{"--"}, positionals...))
+}
+
+// terminated returns whether the parsed arguments end with a "--" that ended
+// the flags, rather than one that is the value of a flag, like "-o --" or,
+// with gnu, a bundle ending with a flag that takes a value, like "-vo --".
+func terminated(fs *flag.FlagSet, parsed []string, gnu bool) bool {
+	n := len(parsed)
+	if n < 1 || parsed[n-1] != "--" {
+		return false
+	}
+	prev := ""
+	if n > 1 {
+		prev = parsed[n-2]
+	}
+	if !strings.HasPrefix(prev, "-") || strings.Contains(prev, "=") {
+		return true
+	}
+
+	// Like parseGNUArgs, the first flag of a bundle that takes a value takes
+	// the rest of the bundle, or the next argument if it is the last one.
+	if gnu && !strings.HasPrefix(prev, "--") {
+		shorts := prev[1:]
+		for i := 0; i < len(shorts); i++ {
+			f := fs.Lookup(shorts[i : i+1])
+			if f == nil {
+				return true
+			}
+			if !isBoolFlag(f.Value) {
+				return i < len(shorts)-1
+			}
+		}
+		return true
+	}
+
+	f := fs.Lookup(strings.TrimLeft(prev, "-"))
+	return f == nil || isBoolFlag(f.Value)
+}
+
+// lookupFlag returns the flag name in fs, or flag.ErrHelp if the flag is
+// not defined and name asks for help.
+func lookupFlag(fs *flag.FlagSet, name string) (*flag.Flag, error) {
+	if f := fs.Lookup(name); f != nil {
+		return f, nil
+	}
+	if name == "h" || name == "help" {
+		return nil, flag.ErrHelp
+	}
+	return nil, fmt.Errorf("flag provided but not defined: %s", flagName(name))
+}
+
+func setFlag(fs *flag.FlagSet, f *flag.Flag, value string) error {
+	if err := fs.Set(f.Name, value); err != nil {
+		return fmt.Errorf("invalid value %q for flag %s: %v", value, flagName(f.Name), err)
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+type gnuFlags struct {
+	all    bool
+	debug  bool
+	quiet  bool
+	output string
+	count  int
+}
+
+func newGNUFlagSet(v *gnuFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("gnu", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&v.all, "a", false, "show all")
+	fs.BoolVar(&v.debug, "debug", false, "enable debug logging")
+	fs.BoolVar(&v.quiet, "quiet", false, "be quiet")
+	fs.StringVar(&v.output, "output", "", "where to save the output")
+	fs.IntVar(&v.count, "count", 0, "how many")
+	Alias(fs, "debug", "d")
+	Alias(fs, "quiet", "q")
+	Alias(fs, "output", "o")
+	Alias(fs, "count", "n")
+	return fs
+}
+
+func TestParseGNU(t *testing.T) {
+	testCases := []struct {
+		args        []string
+		expected    gnuFlags
+		positionals string
+		expectedErr error
+	}{
+		{args: []string{}},
+		{args: []string{"foo", "-d"}, positionals: "foo -d"},
+		{args: []string{"-d"}, expected: gnuFlags{debug: true}},
+		{args: []string{"--debug"}, expected: gnuFlags{debug: true}},
+		{args: []string{"--debug=false"}, expected: gnuFlags{}},
+		{args: []string{"-adq", "foo"}, expected: gnuFlags{all: true, debug: true, quiet: true}, positionals: "foo"},
+		{args: []string{"-ofile"}, expected: gnuFlags{output: "file"}},
+		{args: []string{"-o=file"}, expected: gnuFlags{output: "file"}},
+		{args: []string{"-o", "file", "bar"}, expected: gnuFlags{output: "file"}, positionals: "bar"},
+		{args: []string{"-dofile"}, expected: gnuFlags{debug: true, output: "file"}},
+		{args: []string{"-do", "file"}, expected: gnuFlags{debug: true, output: "file"}},
+		{args: []string{"--output=a=b"}, expected: gnuFlags{output: "a=b"}},
+		{args: []string{"--output", "file"}, expected: gnuFlags{output: "file"}},
+		{args: []string{"--count=3", "-n4"}, expected: gnuFlags{count: 4}},
+		{args: []string{"-d", "--", "-q"}, expected: gnuFlags{debug: true}, positionals: "-q"},
+		{args: []string{"-", "-q"}, positionals: "- -q"},
+		{args: []string{"-x"}, expectedErr: errors.New("flag provided but not defined: -x")},
+		{args: []string{"--nope"}, expectedErr: errors.New("flag provided but not defined: --nope")},
+		{args: []string{"-dx"}, expectedErr: errors.New("flag provided but not defined: -x")},
+		{args: []string{"--output"}, expectedErr: errors.New("flag needs an argument: --output")},
+		{args: []string{"-o"}, expectedErr: errors.New("flag needs an argument: -o")},
+		{args: []string{"--count=many"}, expectedErr: errors.New(`invalid value "many" for flag --count: parse error`)},
+		{args: []string{"-h"}, expectedErr: flag.ErrHelp},
+		{args: []string{"--help"}, expectedErr: flag.ErrHelp},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var v gnuFlags
+			fs := newGNUFlagSet(&v)
+
+			err := parseGNU(fs, tc.args)
+			compareErrors(t, err, tc.expectedErr)
+			if err != nil {
+				return
+			}
+
+			if v != tc.expected {
+				t.Fatalf("expected flags: %+v\ngot: %+v", tc.expected, v)
+			}
+			if positionals := strings.Join(fs.Args(), " "); positionals != tc.positionals {
+				t.Fatalf("expected positional arguments %q, got: %q", tc.positionals, positionals)
+			}
+		})
+	}
+}
+
+func TestProgramGNUFlags(t *testing.T) {
+	var (
+		v      gnuFlags
+		stderr bytes.Buffer
+		args   []string
+	)
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.GNUFlags = true
+	p.FlagSet = newGNUFlagSet(&v)
+	p.Stderr = &stderr
+	p.Action = func(ctx context.Context, a []string) error {
+		args = a
+		return nil
+	}
+
+	if err := p.run(p.defaultContext(), []string{"yo", "-adofile", "--count", "2", "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := gnuFlags{all: true, debug: true, output: "file", count: 2}
+	if v != expected {
+		t.Fatalf("expected flags: %+v\ngot: %+v", expected, v)
+	}
+	if strings.Join(args, " ") != "foo" {
+		t.Fatalf("expected args foo, got: %v", args)
+	}
+
+	// Errors should be usage errors.
+	err := p.run(p.defaultContext(), []string{"yo", "-x"})
+	if code := ExitCode(err); code != ExitUsage {
+		t.Fatalf("expected exit code %d, got: %d (%v)", ExitUsage, code, err)
+	}
+	if !strings.HasPrefix(stderr.String(), "flag provided but not defined: -x\n") {
+		t.Fatalf("expected the error to be printed, got: %q", stderr.String())
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	testCases := []struct {
+		gnu         bool
+		args        []string
+		expected    gnuFlags
+		positionals string
+	}{
+		{args: []string{}},
+		{args: []string{"foo", "-debug", "bar"}, expected: gnuFlags{debug: true}, positionals: "foo bar"},
+		{args: []string{"foo", "-output", "file", "bar", "-a"}, expected: gnuFlags{all: true, output: "file"}, positionals: "foo bar"},
+		{args: []string{"foo", "--", "-a", "bar"}, positionals: "foo -a bar"},
+		{args: []string{"foo", "-output", "--", "-a"}, expected: gnuFlags{all: true, output: "--"}, positionals: "foo"},
+		{args: []string{"-a", "sub", "-debug"}, expected: gnuFlags{all: true}, positionals: "sub -debug"},
+		{args: []string{"foo", "sub", "-debug"}, expected: gnuFlags{debug: true}, positionals: "foo sub"},
+		{gnu: true, args: []string{"foo", "-ado", "file", "bar"}, expected: gnuFlags{all: true, debug: true, output: "file"}, positionals: "foo bar"},
+		{gnu: true, args: []string{"foo", "--", "-a", "--debug"}, positionals: "foo -a --debug"},
+		{gnu: true, args: []string{"foo", "--output", "--", "-q"}, expected: gnuFlags{output: "--", quiet: true}, positionals: "foo"},
+		{gnu: true, args: []string{"-q", "sub", "-d"}, expected: gnuFlags{quiet: true}, positionals: "sub -d"},
+		{gnu: true, args: []string{"foo", "-ao", "--", "bar", "-q"}, expected: gnuFlags{all: true, output: "--", quiet: true}, positionals: "foo bar"},
+		{gnu: true, args: []string{"foo", "-oa", "--", "-q"}, expected: gnuFlags{output: "a"}, positionals: "foo -q"},
+		{gnu: true, args: []string{"foo", "-ad", "--", "-q"}, expected: gnuFlags{all: true, debug: true}, positionals: "foo -q"},
+	}
+
+	for _, tc := range testCases {
+		var v gnuFlags
+		fs := newGNUFlagSet(&v)
+		parse := fs.Parse
+		if tc.gnu {
+			parse = func(args []string) error {
+				return parseGNU(fs, args)
+			}
+		}
+
+		err := parseInterspersed(fs, tc.args, tc.gnu, parse, func(arg string) bool {
+			return arg == "sub"
+		})
+		if err != nil {
+			t.Fatalf("[%s] %v", strings.Join(tc.args, " "), err)
+		}
+		if v != tc.expected {
+			t.Fatalf("[%s] expected flags: %+v\ngot: %+v", strings.Join(tc.args, " "), tc.expected, v)
+		}
+		if positionals := strings.Join(fs.Args(), " "); positionals != tc.positionals {
+			t.Fatalf("[%s] expected positionals %q, got: %q", strings.Join(tc.args, " "), tc.positionals, positionals)
+		}
+	}
+}
+
+func TestProgramInterspersedFlags(t *testing.T) {
+	for _, gnu := range []bool{false, true} {
+		registry := newRegistryCommand()
+		list := registry.tags.list
+
+		p := NewProgram()
+		p.Name = "yo"
+		p.GNUFlags = gnu
+		p.InterspersedFlags = true
+		p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+		p.Commands = []Command{registry}
+
+		err := p.run(p.defaultContext(), []string{"yo", "registry", "tags", "--host", "r.j3ss.co", "ls", "foo", "--all", "bar", "--", "--host"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !list.ran {
+			t.Fatal("expected list command to run")
+		}
+		if registry.host != "r.j3ss.co" {
+			t.Fatalf("expected host flag to be r.j3ss.co, got: %q", registry.host)
+		}
+		if !list.all {
+			t.Fatal("expected all flag to be true")
+		}
+		if strings.Join(list.args, " ") != "foo bar --host" {
+			t.Fatalf("expected args foo bar --host, got: %v", list.args)
+		}
+	}
+}
+
+func TestAliasUsage(t *testing.T) {
+	var (
+		v      gnuFlags
+		stderr bytes.Buffer
+
+		expected = `Flags:
+
+  -a            show all (default: false)
+  -d, --debug   enable debug logging (default: false)
+  -n, --count   how many (default: 0)
+  -o, --output  where to save the output (default: <none>)
+  -q, --quiet   be quiet (default: false)
+
+`
+	)
+
+	p := NewProgram()
+	p.resetFlagUsage(&stderr, newGNUFlagSet(&v))
+	if stderr.String() != expected {
+		t.Fatalf("expected: %q\ngot: %q", expected, stderr.String())
+	}
+}
+
+func TestAliasUndefined(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected Alias to panic for an undefined flag")
+		}
+	}()
+
+	Alias(flag.NewFlagSet("test", flag.ContinueOnError), "nope", "n")
+}
+
+func TestGroupUsage(t *testing.T) {
+	var (
+		debug  bool
+		host   string
+		user   string
+		output string
+		format string
+		stderr bytes.Buffer
+
+		expected = `Usage: yo test` + " " + `
+
+Show the test information.
+
+Global flags:
+
+  -d, --debug  enable debug logging (default: false)
+
+Registry flags:
+
+  --host  the registry host (default: <none>)
+  --user  the registry user (default: <none>)
+
+Output flags:
+
+  --format  the output format (default: table)
+  --output  the output file (default: <none>)
+
+`
+	)
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.Stderr = &stderr
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	Alias(p.FlagSet, "debug", "d")
+
+	// Flags sharing a description should not be combined.
+	p.FlagSet.StringVar(&host, "host", "", "the registry host")
+	p.FlagSet.StringVar(&user, "user", "", "the registry user")
+	p.FlagSet.StringVar(&output, "output", "", "the output file")
+	p.FlagSet.StringVar(&format, "format", "table", "the output format")
+	Group(p.FlagSet, "Registry flags", "user", "host")
+	Group(p.FlagSet, "Output flags", "output", "format")
+	p.Commands = []Command{&testCommand{}}
+
+	err := p.run(p.defaultContext(), []string{"yo", "test", "-h"})
+	compareErrors(t, err, flag.ErrHelp)
+	if stderr.String() != expected {
+		t.Fatalf("expected: %q\ngot: %q", expected, stderr.String())
+	}
+
+	// The grouped flags should still be set and printed by the flag package.
+	if err := p.run(p.defaultContext(), []string{"yo", "test", "-host", "r.j3ss.co"}); err != nil {
+		t.Fatal(err)
+	}
+	if host != "r.j3ss.co" {
+		t.Fatalf("expected host to be set, got: %q", host)
+	}
+	p.FlagSet.SetOutput(ioutil.Discard)
+	p.FlagSet.PrintDefaults()
+}
+
+func TestAliasSameUsage(t *testing.T) {
+	var (
+		a, b   string
+		stderr bytes.Buffer
+
+		expected = `Flags:
+
+  --from  the name (default: <none>)
+  --to    the name (default: <none>)
+
+`
+	)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&a, "from", "", "the name")
+	fs.StringVar(&b, "to", "", "the name")
+
+	p := NewProgram()
+	p.resetFlagUsage(&stderr, fs)
+	if stderr.String() != expected {
+		t.Fatalf("expected: %q\ngot: %q", expected, stderr.String())
+	}
+}
+
+func TestGroupOrder(t *testing.T) {
+	newFlagSet := func() *flag.FlagSet {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		for _, name := range []string{"host", "user", "output", "format"} {
+			fs.String(name, "", "the "+name)
+		}
+		return fs
+	}
+
+	// The groups should be ordered by when they were declared on each
+	// FlagSet, whatever was declared on the others.
+	a := newFlagSet()
+	Group(a, "Registry flags", "host")
+	Group(a, "Output flags", "output")
+	Group(a, "Registry flags", "user")
+
+	b := newFlagSet()
+	Group(b, "Output flags", "output", "format")
+	Group(b, "Registry flags", "host")
+
+	testCases := []struct {
+		fs       *flag.FlagSet
+		name     string
+		expected int
+	}{
+		{a, "host", 1},
+		{a, "user", 1},
+		{a, "output", 2},
+		{a, "format", 0},
+		{b, "output", 1},
+		{b, "format", 1},
+		{b, "host", 2},
+		{b, "user", 0},
+	}
+
+	for _, tc := range testCases {
+		if order := flagMeta(tc.fs.Lookup(tc.name)).groupOrder; order != tc.expected {
+			t.Fatalf("flag %s: expected the group order %d, got: %d", tc.name, tc.expected, order)
+		}
+	}
+}
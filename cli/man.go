@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const manHelp = `Print the man page for the program.`
+
+func (cmd *manCommand) Name() string      { return "man" }
+func (cmd *manCommand) Args() string      { return "" }
+func (cmd *manCommand) ShortHelp() string { return manHelp }
+func (cmd *manCommand) LongHelp() string  { return manHelp }
+func (cmd *manCommand) Hidden() bool      { return true }
+
+func (cmd *manCommand) Register(fs *flag.FlagSet) {}
+
+type manCommand struct {
+	p *Program
+}
+
+// NewManCommand returns a hidden "man" command that prints the man page for
+// the program, to be added to its Commands.
+func NewManCommand(p *Program) Command {
+	return &manCommand{p: p}
+}
+
+func (cmd *manCommand) Run(ctx context.Context, args []string) error {
+	return cmd.p.WriteManPage(Stdout(ctx))
+}
+
+// WriteManPage writes a roff man page for the program to w, generated from its
+// Name, Description, Version, global flags and Commands, with a section for
+// each command that is not hidden. The built-in version command and the
+// --config flag are included, with the default path of the config file shown
+// as $XDG_CONFIG_HOME/<name>/config.json.
+func (p *Program) WriteManPage(w io.Writer) error {
+	p = p.documented()
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, ".TH %s 1 \"\" %s \"User Commands\"\n", roffQuote(strings.ToUpper(p.Name)), roffQuote(p.Name+" "+p.Version))
+
+	// Use the first line of the description as the summary.
+	summary := strings.SplitN(strings.TrimSpace(p.Description), "\n", 2)[0]
+	fmt.Fprintln(&b, ".SH NAME")
+	fmt.Fprintf(&b, "%s \\- %s\n", roffEscape(p.Name), roffEscape(strings.TrimSuffix(summary, ".")))
+
+	fmt.Fprintln(&b, ".SH SYNOPSIS")
+	fmt.Fprintf(&b, ".B %s\n", roffEscape(p.Name))
+	if len(p.Commands) > 0 {
+		fmt.Fprintln(&b, `[\fIflags\fR] \fIcommand\fR [\fIarguments\fR]`)
+	} else {
+		fmt.Fprintln(&b, `[\fIflags\fR] [\fIarguments\fR]`)
+	}
+
+	fmt.Fprintln(&b, ".SH DESCRIPTION")
+	writeRoffText(&b, p.Description)
+
+	// Print information about the common/global flags.
+	if p.FlagSet != nil {
+		if flags := p.flagList(p.FlagSet); len(flags) > 0 {
+			fmt.Fprintln(&b, ".SH OPTIONS")
+			writeRoffFlags(&b, flags)
+		}
+	}
+
+	// Print a section for each command.
+	var commands bytes.Buffer
+	visitCommands(nil, p.Commands, func(path []Command) {
+		command := path[len(path)-1]
+
+		fmt.Fprintf(&commands, ".SS %s\n", roffQuote(strings.TrimSpace(commandPath(path)+" "+commandArgs(command))))
+		writeRoffText(&commands, command.LongHelp())
+		writeRoffFlags(&commands, p.flagList(commandFlags(command)))
+	})
+	if commands.Len() > 0 {
+		fmt.Fprintln(&b, ".SH COMMANDS")
+		b.Write(commands.Bytes())
+	}
+
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// commandPath returns the full name of the command at the end of path, like
+// "registry tags list".
+func commandPath(path []Command) string {
+	names := make([]string, 0, len(path))
+	for _, c := range path {
+		names = append(names, c.Name())
+	}
+	return strings.Join(names, " ")
+}
+
+// writeRoffText writes text as roff paragraphs, split on blank lines.
+func writeRoffText(w io.Writer, text string) {
+	for i, paragraph := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		if i > 0 {
+			fmt.Fprintln(w, ".PP")
+		}
+		for _, line := range strings.Split(paragraph, "\n") {
+			fmt.Fprintln(w, roffEscape(strings.TrimSpace(line)))
+		}
+	}
+}
+
+// writeRoffFlags writes the flags as a roff list.
+func writeRoffFlags(w io.Writer, flags []mflag) {
+	for _, f := range flags {
+		fmt.Fprintln(w, ".TP")
+		fmt.Fprintf(w, "\\fB%s\\fR\n", roffEscape(f.name))
+		var env string
+		if f.env != "" {
+			env = fmt.Sprintf(" [$%s]", f.env)
+		}
+		fmt.Fprintf(w, "%s %s%s\n", roffEscape(f.usage), roffEscape(f.defaultText()), roffEscape(env))
+	}
+}
+
+// roffEscape escapes s to be used as text in a roff document.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+
+	// Lines starting with a period or a quote are requests.
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// roffQuote escapes s and quotes it to be used as an argument of a request.
+func roffQuote(s string) string {
+	return `"` + strings.Replace(roffEscape(s), `"`, `\(dq`, -1) + `"`
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+const expectedManPage = `.TH "YO" 1 "" "yo v0.1.0" "User Commands"
+.SH NAME
+yo \- A tool that prints "yo"
+.SH SYNOPSIS
+.B yo
+[\fIflags\fR] \fIcommand\fR [\fIarguments\fR]
+.SH DESCRIPTION
+A tool that prints "yo".
+.PP
+\&.yo files are read from the current directory.
+.SH OPTIONS
+.TP
+\fB\-d, \-\-debug\fR
+enable debug logging (default: false)
+.SH COMMANDS
+.SS "registry <command>"
+Manage the registry.
+.TP
+\fB\-\-host\fR
+registry host (default: <none>)
+.SS "registry tags <command>"
+Manage the tags.
+.SS "registry tags list [repo...]"
+List the tags.
+.TP
+\fB\-\-all\fR
+list all tags (default: false)
+.SS "test"
+Show the test information.
+.SS "version"
+Show the version information.
+.TP
+\fB\-\-json\fR
+print the version information as JSON (default: false)
+.TP
+\fB\-\-short\fR
+print only the version (default: false)
+`
+
+func TestWriteManPage(t *testing.T) {
+	var debug bool
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.Description = "A tool that prints \"yo\".\n\n.yo files are read from the current directory."
+	p.Version = "v0.1.0"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "d", false, "enable debug logging")
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	Alias(p.FlagSet, "debug", "d")
+	p.Commands = []Command{
+		newRegistryCommand(),
+		&testCommand{},
+		&completionCommand{p: p},
+	}
+
+	var b bytes.Buffer
+	if err := p.WriteManPage(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expectedManPage {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expectedManPage, b.String())
+	}
+
+	// The man command should print the same page.
+	b.Reset()
+	p.Stdout = &b
+	p.Commands = append(p.Commands, NewManCommand(p))
+	if err := p.run(p.defaultContext(), []string{"yo", "man"}); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expectedManPage {
+		t.Fatalf("expected the man command to print the man page, got:\n%s", b.String())
+	}
+}
+
+func TestWriteManPageConfig(t *testing.T) {
+	p := NewProgram()
+	p.Name = "yo"
+	p.LoadConfig = true
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+
+	var b bytes.Buffer
+	if err := p.WriteManPage(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	// The default path of the config file should not depend on the machine.
+	expected := ".TP\n\\fB\\-\\-config\\fR\npath to the config file (default: $XDG_CONFIG_HOME/yo/config.json)\n"
+	if !strings.Contains(b.String(), expected) {
+		t.Fatalf("expected the man page to contain:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	// A path set by the program should be shown as is.
+	b.Reset()
+	p.ConfigFile = "/etc/yo/config.json"
+	if err := p.WriteManPage(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "(default: /etc/yo/config.json)") {
+		t.Fatalf("expected the man page to show the config file of the program, got:\n%s", b.String())
+	}
+}
+
+func TestRoffEscape(t *testing.T) {
+	testCases := []struct {
+		s, expected string
+	}{
+		{"--debug", `\-\-debug`},
+		{`C:\path`, `C:\epath`},
+		{".hidden", `\&.hidden`},
+		{"'quoted'", `\&'quoted'`},
+	}
+
+	for _, tc := range testCases {
+		if s := roffEscape(tc.s); s != tc.expected {
+			t.Fatalf("roffEscape(%q): expected %q, got: %q", tc.s, tc.expected, s)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteMarkdown writes a Markdown reference for the program to dir: an index
+// named after the program, like "yo.md", and a file for each command that is
+// not hidden, like "yo_registry_tags_list.md". The built-in version command
+// and the --config flag are included, like in the man page. The output only
+// depends on the program, so it can be checked into version control and
+// compared in CI.
+func (p *Program) WriteMarkdown(dir string) error {
+	p = p.documented()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	p.writeMarkdownIndex(&b)
+	if err := ioutil.WriteFile(filepath.Join(dir, p.markdownFile(nil)), b.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	var err error
+	visitCommands(nil, p.Commands, func(path []Command) {
+		if err != nil {
+			return
+		}
+
+		var b bytes.Buffer
+		p.writeMarkdownCommand(&b, path)
+		err = ioutil.WriteFile(filepath.Join(dir, p.markdownFile(path)), b.Bytes(), 0644)
+	})
+	return err
+}
+
+// markdownFile returns the name of the file for the command at the end of
+// path, or for the index if path is empty.
+func (p *Program) markdownFile(path []Command) string {
+	names := []string{p.Name}
+	for _, c := range path {
+		names = append(names, c.Name())
+	}
+	return strings.Join(names, "_") + ".md"
+}
+
+func (p *Program) writeMarkdownIndex(w io.Writer) {
+	fmt.Fprintf(w, "# %s\n\n", p.Name)
+	fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(p.Description))
+
+	fmt.Fprintln(w, "## Usage")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "```")
+	if len(p.Commands) > 0 {
+		fmt.Fprintf(w, "%s [flags] <command> [arguments]\n", p.Name)
+	} else {
+		fmt.Fprintf(w, "%s [flags] [arguments]\n", p.Name)
+	}
+	fmt.Fprintln(w, "```")
+
+	// Print information about the common/global flags.
+	if p.FlagSet != nil {
+		p.writeMarkdownFlags(w, "Flags", p.flagList(p.FlagSet))
+	}
+
+	p.writeMarkdownCommands(w, nil, p.Commands)
+}
+
+func (p *Program) writeMarkdownCommand(w io.Writer, path []Command) {
+	command := path[len(path)-1]
+
+	fmt.Fprintf(w, "# %s %s\n\n", p.Name, commandPath(path))
+	fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(command.LongHelp()))
+
+	fmt.Fprintln(w, "## Usage")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "```")
+	fmt.Fprintln(w, strings.TrimSpace(fmt.Sprintf("%s %s %s", p.Name, commandPath(path), commandArgs(command))))
+	fmt.Fprintln(w, "```")
+
+	if a, ok := command.(Aliaser); ok && len(a.Aliases()) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Aliases: %s\n", markdownCode(a.Aliases()...))
+	}
+
+	p.writeMarkdownFlags(w, "Flags", p.flagList(commandFlags(command)))
+
+	if parent, ok := command.(Subcommander); ok {
+		p.writeMarkdownCommands(w, path, parent.Subcommands())
+	}
+
+	// Link back to the parent.
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## See also")
+	fmt.Fprintln(w)
+	parent := fmt.Sprintf("%s %s", p.Name, commandPath(path[:len(path)-1]))
+	fmt.Fprintf(w, "- [%s](%s)\n", strings.TrimSpace(parent), p.markdownFile(path[:len(path)-1]))
+}
+
+// writeMarkdownFlags writes a table of the flags under a section with title.
+func (p *Program) writeMarkdownFlags(w io.Writer, title string, flags []mflag) {
+	if len(flags) < 1 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "## %s\n\n", title)
+	fmt.Fprintln(w, "| Flag | Description | Default |")
+	fmt.Fprintln(w, "|------|-------------|---------|")
+	for _, f := range flags {
+		usage := f.usage
+		if f.env != "" {
+			usage += fmt.Sprintf(" (env: `$%s`)", f.env)
+		}
+		def := markdownCode(f.defValue)
+		if f.required {
+			def = "required"
+		}
+		fmt.Fprintf(w, "| %s | %s | %s |\n", markdownCode(f.name), markdownCell(usage), def)
+	}
+}
+
+// writeMarkdownCommands writes a table linking to the commands under path.
+func (p *Program) writeMarkdownCommands(w io.Writer, path []Command, commands []Command) {
+	var rows bytes.Buffer
+	for _, command := range commands {
+		if command.Hidden() {
+			continue
+		}
+
+		childPath := append(append([]Command{}, path...), command)
+		fmt.Fprintf(&rows, "| [%s](%s) | %s |\n", command.Name(), p.markdownFile(childPath), markdownCell(command.ShortHelp()))
+	}
+	if rows.Len() < 1 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## Commands")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Command | Description |")
+	fmt.Fprintln(w, "|---------|-------------|")
+	rows.WriteTo(w)
+}
+
+// markdownCell escapes s to be used in a table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(strings.TrimSpace(s))
+}
+
+// markdownCode formats each of the values as inline code, separated by commas.
+func markdownCode(values ...string) string {
+	code := make([]string, 0, len(values))
+	for _, v := range values {
+		code = append(code, "`"+strings.Replace(v, "|", `\|`, -1)+"`")
+	}
+	return strings.Join(code, ", ")
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const (
+	expectedMarkdownIndex = "# yo\n\n" +
+		"A tool that prints \"yo\".\n\n" +
+		"## Usage\n\n" +
+		"```\nyo [flags] <command> [arguments]\n```\n\n" +
+		"## Flags\n\n" +
+		"| Flag | Description | Default |\n" +
+		"|------|-------------|---------|\n" +
+		"| `--config` | path to the config file | `$XDG_CONFIG_HOME/yo/config.json` |\n" +
+		"| `-d, --debug` | enable debug logging | `false` |\n\n" +
+		"## Commands\n\n" +
+		"| Command | Description |\n" +
+		"|---------|-------------|\n" +
+		"| [registry](yo_registry.md) | Manage the registry. |\n" +
+		"| [test](yo_test.md) | Show the test information. |\n" +
+		"| [version](yo_version.md) | Show the version information. |\n"
+
+	expectedMarkdownList = "# yo registry tags list\n\n" +
+		"List the tags.\n\n" +
+		"## Usage\n\n" +
+		"```\nyo registry tags list [repo...]\n```\n\n" +
+		"Aliases: `ls`\n\n" +
+		"## Flags\n\n" +
+		"| Flag | Description | Default |\n" +
+		"|------|-------------|---------|\n" +
+		"| `--all` | list all tags | `false` |\n\n" +
+		"## See also\n\n" +
+		"- [yo registry tags](yo_registry_tags.md)\n"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	var debug bool
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.Description = `A tool that prints "yo".`
+	p.LoadConfig = true
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.BoolVar(&debug, "d", false, "enable debug logging")
+	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
+	Alias(p.FlagSet, "debug", "d")
+	p.Commands = []Command{
+		newRegistryCommand(),
+		&testCommand{},
+		&completionCommand{p: p},
+	}
+
+	dir, err := ioutil.TempDir("", "cli-markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := p.WriteMarkdown(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	expectedNames := "yo.md yo_registry.md yo_registry_tags.md yo_registry_tags_list.md yo_test.md yo_version.md"
+	if strings.Join(names, " ") != expectedNames {
+		t.Fatalf("expected files: %s\ngot: %s", expectedNames, strings.Join(names, " "))
+	}
+
+	for file, expected := range map[string]string{
+		"yo.md":                    expectedMarkdownIndex,
+		"yo_registry_tags_list.md": expectedMarkdownList,
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Fatalf("%s: expected:\n%s\ngot:\n%s", file, expected, string(b))
+		}
+	}
+}
+
+func TestMarkdownCell(t *testing.T) {
+	if s := markdownCell(" a | b\nc "); s != `a \| b c` {
+		t.Fatalf("expected the cell to be escaped, got: %q", s)
+	}
+}
@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInterrupted is returned by readLine when the line is canceled with
+// Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads lines from a terminal in raw mode, with the usual emacs
+// style keys to edit the line, a history to go through with the arrow keys and
+// completion with the tab key.
+type lineEditor struct {
+	in     *bufio.Reader
+	out    io.Writer
+	prompt string
+
+	// history of lines, oldest first.
+	history []string
+	// complete returns the candidates for the last word of line, if set.
+	complete func(line string) []string
+
+	line []rune
+	pos  int
+}
+
+// readLine reads a line. It returns io.EOF if Ctrl-D is pressed on an empty
+// line and errInterrupted if Ctrl-C is pressed.
+func (e *lineEditor) readLine() (string, error) {
+	e.line, e.pos = nil, 0
+	index, draft := len(e.history), ""
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n': // Enter
+			fmt.Fprint(e.out, "\n")
+			return string(e.line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(e.line) < 1 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			e.delete(e.pos, e.pos+1)
+		case 1: // Ctrl-A
+			e.pos = 0
+		case 5: // Ctrl-E
+			e.pos = len(e.line)
+		case 2: // Ctrl-B
+			e.move(-1)
+		case 6: // Ctrl-F
+			e.move(1)
+		case 127, 8: // Backspace, Ctrl-H
+			e.delete(e.pos-1, e.pos)
+		case 11: // Ctrl-K
+			e.delete(e.pos, len(e.line))
+		case 21: // Ctrl-U
+			e.delete(0, e.pos)
+		case 23: // Ctrl-W
+			e.delete(e.wordStart(), e.pos)
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16, 14: // Ctrl-P, Ctrl-N
+			index, draft = e.walkHistory(r == 16, index, draft)
+		case '\t':
+			e.completeWord()
+		case 27: // Escape sequences, like the arrow keys.
+			switch e.readEscape() {
+			case "[A", "OA":
+				index, draft = e.walkHistory(true, index, draft)
+			case "[B", "OB":
+				index, draft = e.walkHistory(false, index, draft)
+			case "[C", "OC":
+				e.move(1)
+			case "[D", "OD":
+				e.move(-1)
+			case "[H", "OH", "[1~", "[7~":
+				e.pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				e.pos = len(e.line)
+			case "[3~":
+				e.delete(e.pos, e.pos+1)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+
+		e.refresh()
+	}
+}
+
+// refresh redraws the prompt and the line, with the cursor at its position.
+func (e *lineEditor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.line))
+	if n := len(e.line) - e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+// readEscape reads the rest of an escape sequence, like "[A" for the up arrow.
+func (e *lineEditor) readEscape() string {
+	var seq []rune
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, r)
+
+		// The sequences end with a letter or a tilde, after the opening
+		// bracket or O.
+		if len(seq) > 1 && (unicode.IsLetter(r) || r == '~') {
+			return string(seq)
+		}
+		if len(seq) == 1 && r != '[' && r != 'O' {
+			return string(seq)
+		}
+	}
+}
+
+// move moves the cursor by n runes, staying on the line.
+func (e *lineEditor) move(n int) {
+	e.pos += n
+	if e.pos < 0 {
+		e.pos = 0
+	}
+	if e.pos > len(e.line) {
+		e.pos = len(e.line)
+	}
+}
+
+// insert inserts runes at the cursor.
+func (e *lineEditor) insert(runes []rune) {
+	line := append(append(append([]rune{}, e.line[:e.pos]...), runes...), e.line[e.pos:]...)
+	e.line, e.pos = line, e.pos+len(runes)
+}
+
+// delete deletes the runes from start up to end, if they are on the line.
+func (e *lineEditor) delete(start, end int) {
+	if start < 0 || end > len(e.line) || start >= end {
+		return
+	}
+	e.line = append(e.line[:start], e.line[end:]...)
+	e.pos = start
+}
+
+// wordStart returns the start of the word before the cursor.
+func (e *lineEditor) wordStart() int {
+	i := e.pos
+	for i > 0 && unicode.IsSpace(e.line[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(e.line[i-1]) {
+		i--
+	}
+	return i
+}
+
+// walkHistory replaces the line with the previous or next line in the
+// history. The line being edited is kept as the draft, to come back to after
+// the newest line.
+func (e *lineEditor) walkHistory(back bool, index int, draft string) (int, string) {
+	if index == len(e.history) {
+		draft = string(e.line)
+	}
+
+	switch {
+	case back && index > 0:
+		index--
+	case !back && index < len(e.history):
+		index++
+	default:
+		return index, draft
+	}
+
+	line := draft
+	if index < len(e.history) {
+		line = e.history[index]
+	}
+	e.line = []rune(line)
+	e.pos = len(e.line)
+	return index, draft
+}
+
+// completeWord completes the word before the cursor. A single candidate is
+// completed in full, otherwise the word is extended to the longest prefix the
+// candidates share, or the candidates are listed if there is none.
+func (e *lineEditor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	start := e.pos
+	for start > 0 && !unicode.IsSpace(e.line[start-1]) {
+		start--
+	}
+	word := string(e.line[start:e.pos])
+
+	candidates := e.complete(string(e.line[:e.pos]))
+	switch len(candidates) {
+	case 0:
+		return
+	case 1:
+		e.delete(start, e.pos)
+		e.insert([]rune(candidates[0] + " "))
+		return
+	}
+
+	if prefix := commonPrefix(candidates); len(prefix) > len(word) {
+		e.delete(start, e.pos)
+		e.insert([]rune(prefix))
+		return
+	}
+
+	sort.Strings(candidates)
+	fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+}
+
+// commonPrefix returns the longest prefix of all the words.
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLineEditor(t *testing.T) {
+	testCases := []struct {
+		description string
+		input       string
+		history     []string
+		candidates  []string
+		expected    string
+		expectedErr error
+	}{
+		{description: "enter", input: "hello\r", expected: "hello"},
+		{description: "insert after moving left", input: "helo\x1b[Dl\r", expected: "hello"},
+		{description: "insert at the start", input: "ello\x01h\r", expected: "hello"},
+		{description: "move to the end", input: "hell\x01\x05o\r", expected: "hello"},
+		{description: "backspace", input: "helloo\x7f\r", expected: "hello"},
+		{description: "delete", input: "hhello\x01\x1b[3~\r", expected: "hello"},
+		{description: "delete the previous word", input: "hello world\x17\r", expected: "hello "},
+		{description: "delete to the start", input: "hello world\x02\x02\x02\x02\x02\x15\r", expected: "world"},
+		{description: "delete to the end", input: "hello world\x01\x1b[C\x1b[C\x1b[C\x1b[C\x1b[C\x0b\r", expected: "hello"},
+		{description: "unicode", input: "héllo\x7f\x7fo\r", expected: "hélo"},
+		{description: "previous line", input: "\x1b[A\r", history: []string{"first", "second"}, expected: "second"},
+		{description: "oldest line", input: "\x1b[A\x1b[A\x1b[A\r", history: []string{"first", "second"}, expected: "first"},
+		{description: "back to the draft", input: "dr\x10\x0eaft\r", history: []string{"first"}, expected: "draft"},
+		{description: "complete a single candidate", input: "reg\t\r", candidates: []string{"registry"}, expected: "registry "},
+		{description: "complete the common prefix", input: "t\t\r", candidates: []string{"tags-list", "tags-ls"}, expected: "tags-l"},
+		{description: "list the candidates", input: "l\t\r", candidates: []string{"list", "ls"}, expected: "l"},
+		{description: "interrupt", input: "hello\x03", expectedErr: errInterrupted},
+		{description: "end of input", input: "\x04", expectedErr: io.EOF},
+		{description: "delete at the cursor", input: "hello\x01\x04\r", expected: "ello"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			e := &lineEditor{
+				in:      bufio.NewReader(strings.NewReader(tc.input)),
+				out:     ioutil.Discard,
+				prompt:  "> ",
+				history: tc.history,
+				complete: func(line string) []string {
+					return tc.candidates
+				},
+			}
+
+			line, err := e.readLine()
+			if err != tc.expectedErr {
+				t.Fatalf("expected error %v, got: %v", tc.expectedErr, err)
+			}
+			if line != tc.expected {
+				t.Fatalf("expected line %q, got: %q", tc.expected, line)
+			}
+		})
+	}
+}
+
+func TestLineEditorOutput(t *testing.T) {
+	var out strings.Builder
+	e := &lineEditor{
+		in:     bufio.NewReader(strings.NewReader("ab\x1b[D\r")),
+		out:    &out,
+		prompt: "> ",
+	}
+	if _, err := e.readLine(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The line should be redrawn after each key, with the cursor moved back
+	// when it is not at the end.
+	expected := "\r> \x1b[K\r> a\x1b[K\r> ab\x1b[K\r> ab\x1b[K\x1b[1D\n"
+	if out.String() != expected {
+		t.Fatalf("expected: %q\ngot: %q", expected, out.String())
+	}
+}
@@ -0,0 +1,401 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const shellHelp = `Start an interactive shell to run the commands.`
+
+// ShellCommand is a command that reads lines from the input and runs them as
+// the commands of the program, like "prog shell" followed by "list -all".
+// Create it with NewShellCommand and add it to the Commands of the program.
+//
+// The program's Before is run once before the shell starts, and After and
+// Finally once it exits, so the commands share the context and whatever
+// Before set up, like connections. Each line is parsed like a new run of the
+// program, with the global flags before the command name. The global flags
+// given on a line only apply to that line, and are then set back to the
+// values they had when the shell started.
+//
+// On a terminal, the line can be edited with the arrow keys and the usual
+// emacs keys, Up and Down go through the history and Tab completes the
+// commands, flags and the arguments of commands implementing Completer. Enter
+// "exit" or press Ctrl-D to exit. An interrupt while a command is running
+// cancels the shared context, so the shell exits once the command returns.
+type ShellCommand struct {
+	// Prompt is printed before each line on a terminal.
+	Prompt string
+	// HistoryFile is the file the lines are saved to, and read from when the
+	// shell starts. If empty, the history is not saved.
+	HistoryFile string
+	// HistorySize is the number of lines kept in the history file.
+	HistorySize int
+
+	p *Program
+}
+
+// NewShellCommand returns a "shell" command for the program, with a prompt
+// like "prog> " and the history saved to DefaultHistoryFile.
+func NewShellCommand(p *Program) *ShellCommand {
+	return &ShellCommand{
+		Prompt:      p.Name + "> ",
+		HistoryFile: DefaultHistoryFile(p.Name),
+		HistorySize: 1000,
+		p:           p,
+	}
+}
+
+// DefaultHistoryFile returns the path of the shell history file for the program
+// name, like ~/.local/state/name/history. It uses $XDG_STATE_HOME if set.
+func DefaultHistoryFile(name string) string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".local", "state")
+	}
+	return filepath.Join(dir, name, "history")
+}
+
+// Name returns the name of the command, "shell".
+func (cmd *ShellCommand) Name() string { return "shell" }
+
+// Args returns the arguments of the command, which has none.
+func (cmd *ShellCommand) Args() string { return "" }
+
+// ShortHelp returns the description of the command.
+func (cmd *ShellCommand) ShortHelp() string { return shellHelp }
+
+// LongHelp returns the description of the command.
+func (cmd *ShellCommand) LongHelp() string { return shellHelp }
+
+// Hidden returns false, since the shell is shown in the usage.
+func (cmd *ShellCommand) Hidden() bool { return false }
+
+// Register does nothing, since the command has no flags.
+func (cmd *ShellCommand) Register(fs *flag.FlagSet) {}
+
+// Run reads and runs lines until the input ends or "exit" is entered.
+func (cmd *ShellCommand) Run(ctx context.Context, args []string) error {
+	history, err := cmd.loadHistory()
+	if err != nil {
+		return err
+	}
+
+	// Save the global flags, to set them back after each line.
+	saved := flagValues(cmd.p.running(ctx).FlagSet)
+
+	var (
+		in       = bufio.NewReader(Stdin(ctx))
+		readLine = func() (string, error) {
+			return readPlainLine(in)
+		}
+	)
+
+	// Edit the lines in raw mode on a terminal.
+	if f, ok := Stdin(ctx).(*os.File); ok && isTerminal(int(f.Fd())) {
+		editor := &lineEditor{
+			in:     in,
+			out:    Stdout(ctx),
+			prompt: cmd.Prompt,
+			complete: func(line string) []string {
+				return cmd.p.running(ctx).shellComplete(ctx, line)
+			},
+		}
+		readLine = func() (string, error) {
+			// Only stay in raw mode while reading, so the commands run on a
+			// normal terminal.
+			state, err := makeRaw(int(f.Fd()))
+			if err != nil {
+				return "", err
+			}
+			defer restoreTerminal(int(f.Fd()), state)
+
+			editor.history = history
+			return editor.readLine()
+		}
+	}
+
+	for ctx.Err() == nil {
+		line, err := readLine()
+		if err == errInterrupted {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(history) < 1 || history[len(history)-1] != line {
+			history = append(history, line)
+			if err := cmd.appendHistory(line); err != nil {
+				fmt.Fprintf(Stderr(ctx), "saving the history failed: %v\n", err)
+			}
+		}
+
+		words, err := splitWords(line)
+		if err != nil {
+			fmt.Fprintln(Stderr(ctx), err)
+			continue
+		}
+		switch words[0] {
+		case "exit", "quit":
+			return nil
+		case cmd.Name():
+			fmt.Fprintln(Stderr(ctx), "already in the shell")
+			continue
+		}
+
+		cmd.runLine(ctx, words, saved)
+	}
+	return nil
+}
+
+// runLine runs the words of a line as a run of the program, without the
+// program's Before, After and Finally, which are run for the shell, and prints
+// the error, if any. The global flags are then set back to the saved values.
+func (cmd *ShellCommand) runLine(ctx context.Context, words []string, saved map[string]string) {
+	r := cmd.p.copy()
+	r.Before, r.After, r.Finally = nil, nil, nil
+	defer func() {
+		if err := restoreFlags(r.FlagSet, saved); err != nil {
+			fmt.Fprintln(Stderr(ctx), err)
+		}
+	}()
+
+	// Print the error, unless it was printed along with the usage.
+	err := r.execUsage(ctx, append([]string{r.Name}, words...))
+	if err != nil && !isHelp(err) && !r.usagePrinted {
+		fmt.Fprintln(Stderr(ctx), err)
+	}
+}
+
+// resetter is implemented by flag values that can be set back to their
+// default, like values.StringSlice, which appends to the slice once it is set.
+type resetter interface {
+	Reset()
+}
+
+// flagValues returns the values of the flags in fs by name.
+func flagValues(fs *flag.FlagSet) map[string]string {
+	saved := map[string]string{}
+	if fs == nil {
+		return saved
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		saved[f.Name] = f.Value.String()
+	})
+	return saved
+}
+
+// restoreFlags sets the flags in fs that changed back to the values saved by
+// flagValues. Values implementing resetter are reset first, so they are not
+// appended to.
+func restoreFlags(fs *flag.FlagSet, saved map[string]string) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := saved[f.Name]
+		if !ok || err != nil || f.Value.String() == value {
+			return
+		}
+		if v, ok := f.Value.(resetter); ok {
+			v.Reset()
+			if f.Value.String() == value {
+				return
+			}
+		}
+		if e := f.Value.Set(value); e != nil {
+			err = fmt.Errorf("restoring the flag -%s failed: %v", f.Name, e)
+		}
+	})
+	return err
+}
+
+// loadHistory reads the last HistorySize lines of the history file. A missing
+// file is not an error. If the file holds more lines, it is trimmed.
+func (cmd *ShellCommand) loadHistory() ([]string, error) {
+	if cmd.HistoryFile == "" {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadFile(cmd.HistoryFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading the history failed: %v", err)
+	}
+
+	history := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(history) == 1 && history[0] == "" {
+		return nil, nil
+	}
+	if cmd.HistorySize > 0 && len(history) > cmd.HistorySize {
+		history = history[len(history)-cmd.HistorySize:]
+		data := strings.Join(history, "\n") + "\n"
+		if err := ioutil.WriteFile(cmd.HistoryFile, []byte(data), 0600); err != nil {
+			return nil, fmt.Errorf("trimming the history failed: %v", err)
+		}
+	}
+	return history, nil
+}
+
+// appendHistory appends line to the history file.
+func (cmd *ShellCommand) appendHistory(line string) error {
+	if cmd.HistoryFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cmd.HistoryFile), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(cmd.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readPlainLine reads a line from in, for input that is not a terminal.
+func readPlainLine(in *bufio.Reader) (string, error) {
+	line, err := in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		return line, nil
+	}
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+// shellComplete returns the candidates for the last word of line in the shell,
+// from the command tree and the Completer of the command, if any.
+func (p *Program) shellComplete(ctx context.Context, line string) []string {
+	words := strings.Fields(line)
+	toComplete := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		toComplete = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	// Walk down the tree to the node of the command named by the words. Like
+	// the completion scripts, words that do not name a child are skipped.
+	nodes := p.completionTree()
+	node := nodes[0]
+	for _, word := range words {
+		for _, c := range node.commands {
+			if c.name != word {
+				continue
+			}
+			path := strings.Join(append(append([]string{}, node.path...), c.canonical), " ")
+			for _, n := range nodes {
+				if strings.Join(n.path, " ") == path {
+					node = n
+					break
+				}
+			}
+			break
+		}
+	}
+
+	var candidates []string
+	if strings.HasPrefix(toComplete, "-") {
+		candidates = node.flags
+	} else {
+		for _, c := range node.commands {
+			candidates = append(candidates, c.name)
+		}
+		if node.dynamic {
+			candidates = append(candidates, p.complete(ctx, words, toComplete)...)
+		}
+	}
+
+	// Keep the candidates starting with the word, once each.
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, toComplete) && !contains(matches, candidate) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// splitWords splits line into words like a shell does, on unquoted spaces.
+// Single quotes keep everything in them. In double quotes, a backslash only
+// escapes a quote or a backslash, and is kept before any other character.
+// Outside of quotes, a backslash escapes the next character.
+func splitWords(line string) ([]string, error) {
+	var (
+		words []string
+		word  []rune
+		// inWord is whether a word was started, which may be empty, like "".
+		inWord bool
+		quote  rune
+		escape bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escape:
+			// In double quotes, a backslash only escapes quotes and
+			// backslashes, and is kept before anything else.
+			if quote == '"' && r != '"' && r != '\\' {
+				word = append(word, '\\')
+			}
+			word, escape = append(word, r), false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+				continue
+			}
+			word = append(word, r)
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escape = true
+			default:
+				word = append(word, r)
+			}
+		case r == '\\':
+			escape, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, string(word))
+			}
+			word, inWord = nil, false
+		default:
+			word, inWord = append(word, r), true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escape {
+		return nil, errors.New("unterminated escape")
+	}
+	if inWord {
+		words = append(words, string(word))
+	}
+	return words, nil
+}
@@ -0,0 +1,269 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/genuinetools/pkg/cli/values"
+)
+
+func TestSplitWords(t *testing.T) {
+	testCases := []struct {
+		line        string
+		expected    []string
+		expectedErr error
+	}{
+		{line: "", expected: nil},
+		{line: "  list  -all\tfoo ", expected: []string{"list", "-all", "foo"}},
+		{line: `echo 'a b' "c d"`, expected: []string{"echo", "a b", "c d"}},
+		{line: `echo '' ""`, expected: []string{"echo", "", ""}},
+		{line: `echo 'a\b' "a\"b\\"`, expected: []string{"echo", `a\b`, `a"b\`}},
+		{line: `ls "C:\Temp" "a\$b\n"`, expected: []string{"ls", `C:\Temp`, `a\$b\n`}},
+		{line: `echo a\ b \'`, expected: []string{"echo", "a b", "'"}},
+		{line: `echo a"b c"d`, expected: []string{"echo", "ab cd"}},
+		{line: `echo 'a b`, expectedErr: errors.New("unterminated ' quote")},
+		{line: `echo "a b`, expectedErr: errors.New(`unterminated " quote`)},
+		{line: `echo a\`, expectedErr: errors.New("unterminated escape")},
+		{line: `echo "a\`, expectedErr: errors.New(`unterminated " quote`)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			words, err := splitWords(tc.line)
+			compareErrors(t, err, tc.expectedErr)
+			if !reflect.DeepEqual(words, tc.expected) {
+				t.Fatalf("expected words %q, got: %q", tc.expected, words)
+			}
+		})
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli-shell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		before, after  int
+		stdout, stderr bytes.Buffer
+	)
+
+	p := newCompletionProgram()
+	list := p.Commands[0].(*registryCommand).tags.list
+	shell := NewShellCommand(p)
+	shell.HistoryFile = filepath.Join(dir, "yo", "history")
+	p.Commands = append(p.Commands, shell)
+	p.Before = func(ctx context.Context) error {
+		before++
+		return nil
+	}
+	p.After = func(ctx context.Context) error {
+		after++
+		return nil
+	}
+	p.Stdin = strings.NewReader(strings.Join([]string{
+		"registry tags list -all alpine",
+		"",
+		"nope",
+		"-bogus",
+		"shell",
+		"registry tags ls 'busy box'",
+		"registry tags ls 'busy box'",
+		"exit",
+		"registry tags list nginx",
+	}, "\n"))
+	p.Stdout = &stdout
+	p.Stderr = &stderr
+
+	if err := p.run(p.defaultContext(), []string{"yo", "shell"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The commands should share the run of the shell.
+	if before != 1 || after != 1 {
+		t.Fatalf("expected Before and After to be run once, got: %d and %d", before, after)
+	}
+
+	// The flags should be reset for each line, and the shell should exit
+	// before the last line.
+	if !list.ran || list.all || !reflect.DeepEqual(list.args, []string{"busy box"}) {
+		t.Fatalf("expected list to be run with [busy box] and no -all, got: %q, all: %t", list.args, list.all)
+	}
+
+	for _, expected := range []string{
+		"nope: no such command",
+		"flag provided but not defined: -bogus",
+		"already in the shell",
+	} {
+		if !strings.Contains(stderr.String(), expected) {
+			t.Fatalf("expected the output to contain %q, got:\n%s", expected, stderr.String())
+		}
+	}
+
+	// The lines should be saved, without repeats.
+	b, err := ioutil.ReadFile(shell.HistoryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "registry tags list -all alpine\nnope\n-bogus\nshell\nregistry tags ls 'busy box'\nexit\n"
+	if string(b) != expected {
+		t.Fatalf("expected the history:\n%s\ngot:\n%s", expected, b)
+	}
+}
+
+// Define the printCommand, which prints the global flags.
+type printCommand struct {
+	print func() string
+}
+
+func (cmd *printCommand) Name() string              { return "print" }
+func (cmd *printCommand) Args() string              { return "" }
+func (cmd *printCommand) ShortHelp() string         { return "Print the global flags." }
+func (cmd *printCommand) LongHelp() string          { return "Print the global flags." }
+func (cmd *printCommand) Hidden() bool              { return false }
+func (cmd *printCommand) Register(fs *flag.FlagSet) {}
+func (cmd *printCommand) Run(ctx context.Context, args []string) error {
+	_, err := fmt.Fprintln(Stdout(ctx), cmd.print())
+	return err
+}
+
+func TestShellGlobalFlags(t *testing.T) {
+	var (
+		host           string
+		tags           []string
+		stdout, stderr bytes.Buffer
+	)
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.StringVar(&host, "host", "", "registry host")
+	p.FlagSet.Var(values.NewStringSlice(&tags, []string{"latest"}), "tag", "tag to push")
+	shell := NewShellCommand(p)
+	shell.HistoryFile = ""
+	p.Commands = []Command{
+		&printCommand{print: func() string { return host + " " + strings.Join(tags, ",") }},
+		shell,
+	}
+	p.Stdin = strings.NewReader(strings.Join([]string{
+		"print",
+		"-host b -tag x -tag y print",
+		"print",
+		"-tag z print",
+	}, "\n"))
+	p.Stdout = &stdout
+	p.Stderr = &stderr
+
+	if err := p.run(p.defaultContext(), []string{"yo", "-host", "a", "shell"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The flags given on a line should only apply to that line.
+	expected := "a latest\nb x,y\na latest\na z\n"
+	if stdout.String() != expected {
+		t.Fatalf("expected stdout:\n%s\ngot:\n%s\nstderr: %s", expected, stdout.String(), stderr.String())
+	}
+	if host != "a" || !reflect.DeepEqual(tags, []string{"latest"}) {
+		t.Fatalf("expected the flags of the shell to be restored, got: %q and %q", host, tags)
+	}
+}
+
+func TestShellHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli-shell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	shell := NewShellCommand(NewProgram())
+	shell.HistoryFile = filepath.Join(dir, "history")
+	shell.HistorySize = 2
+
+	// A missing file should be an empty history.
+	history, err := shell.loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("expected no history, got: %q", history)
+	}
+
+	for _, line := range []string{"one", "two", "three"} {
+		if err := shell.appendHistory(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The history should be trimmed to the last lines.
+	history, err = shell.loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(history, []string{"two", "three"}) {
+		t.Fatalf("expected the last two lines, got: %q", history)
+	}
+	b, err := ioutil.ReadFile(shell.HistoryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "two\nthree\n" {
+		t.Fatalf("expected the file to be trimmed, got: %q", b)
+	}
+}
+
+func TestDefaultHistoryFile(t *testing.T) {
+	state, home := os.Getenv("XDG_STATE_HOME"), os.Getenv("HOME")
+	defer os.Setenv("XDG_STATE_HOME", state)
+	defer os.Setenv("HOME", home)
+
+	os.Setenv("HOME", "/home/jess")
+	os.Setenv("XDG_STATE_HOME", "")
+	if file := DefaultHistoryFile("yo"); file != "/home/jess/.local/state/yo/history" {
+		t.Fatalf("expected the file in ~/.local/state, got: %s", file)
+	}
+
+	os.Setenv("XDG_STATE_HOME", "/state")
+	if file := DefaultHistoryFile("yo"); file != "/state/yo/history" {
+		t.Fatalf("expected the file in $XDG_STATE_HOME, got: %s", file)
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected []string
+	}{
+		{"", []string{"registry", "test"}},
+		{"re", []string{"registry"}},
+		{"-", []string{"-d"}},
+		{"registry ", []string{"tags"}},
+		{"registry --", []string{"--host"}},
+		{"registry tags ", []string{"list", "ls"}},
+		{"registry tags ls ", []string{"alpine", "busybox", "nginx"}},
+		{"registry tags list alpine b", []string{"busybox"}},
+		{"registry tags list --a", []string{"--all"}},
+		{"nope ", []string{"registry", "test"}},
+		{"test ", nil},
+	}
+
+	p := newCompletionProgram()
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			candidates := p.shellComplete(context.Background(), tc.line)
+			if !reflect.DeepEqual(candidates, tc.expected) {
+				t.Fatalf("expected %q, got: %q", tc.expected, candidates)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// signals are the signals that cancel the context of a program started with
+// Run.
+var signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// handleSignals returns a copy of ctx that is canceled when the program
+// receives an interrupt or termination signal. After that, a second signal or
+// the grace period expiring forces the program to exit. The returned function
+// stops handling the signals and must be called once the program is done.
+func (p *Program) handleSignals(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+
+	done := make(chan struct{})
+	go func() {
+		// Wait for the first signal and cancel the context. Let the user know,
+		// since a program that does not watch the context keeps running.
+		select {
+		case sig := <-c:
+			fmt.Fprintf(p.stderr(), "Received %s, shutting down (press again to force).\n", sig)
+			cancel()
+		case <-done:
+			return
+		}
+
+		// Wait for the program to shut down on its own.
+		var timeout <-chan time.Time
+		if p.GracePeriod > 0 {
+			timer := time.NewTimer(p.GracePeriod)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case sig := <-c:
+			fmt.Fprintf(p.stderr(), "Received %s again, exiting.\n", sig)
+			os.Exit(signalExitCode(sig))
+		case <-timeout:
+			fmt.Fprintf(p.stderr(), "Did not shut down within %s, exiting.\n", p.GracePeriod)
+			os.Exit(ExitFailure)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(c)
+		close(done)
+		cancel()
+	}
+}
+
+// signalExitCode returns the conventional exit code for a program killed by
+// sig, which is 128 plus the signal number. The numbers are spelled out, since
+// not every platform has numbered signals.
+func signalExitCode(sig os.Signal) int {
+	switch sig {
+	case os.Interrupt:
+		// SIGINT.
+		return 128 + 2
+	case syscall.SIGTERM:
+		return 128 + 15
+	}
+	return ExitFailure
+}
+
+// withoutCancel returns a context carrying the values of ctx that is never
+// canceled, so cleanup can still run after ctx has been.
+func withoutCancel(ctx context.Context) context.Context {
+	return uncanceledContext{ctx}
+}
+
+type uncanceledContext struct {
+	context.Context
+}
+
+func (uncanceledContext) Deadline() (deadline time.Time, ok bool) { return }
+func (uncanceledContext) Done() <-chan struct{}                   { return nil }
+func (uncanceledContext) Err() error                              { return nil }
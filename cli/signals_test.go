@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending signals is not supported on windows")
+	}
+
+	var stderr bytes.Buffer
+
+	p := NewProgram()
+	p.Stderr = &stderr
+	ctx, stop := p.handleSignals(context.Background())
+	defer stop()
+
+	proc, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proc.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the context to be canceled by the signal")
+	}
+
+	// The first signal should not go unnoticed.
+	expected := "Received terminated, shutting down (press again to force).\n"
+	if stderr.String() != expected {
+		t.Fatalf("expected stderr: %q\ngot: %q", expected, stderr.String())
+	}
+}
+
+func TestWithoutCancel(t *testing.T) {
+	key := ContextKey("test.key")
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key, "value"))
+	cancel()
+
+	ctx = withoutCancel(ctx)
+	if ctx.Err() != nil {
+		t.Fatalf("expected no error, got: %v", ctx.Err())
+	}
+	if ctx.Done() != nil {
+		t.Fatal("expected a nil done channel")
+	}
+	if v := ctx.Value(key); v != "value" {
+		t.Fatalf("expected context value %q, got: %v", "value", v)
+	}
+}
+
+func TestSignalExitCode(t *testing.T) {
+	testCases := []struct {
+		sig      os.Signal
+		expected int
+	}{
+		{os.Interrupt, 130},
+		{syscall.SIGTERM, 143},
+		{os.Kill, ExitFailure},
+	}
+
+	for _, tc := range testCases {
+		if code := signalExitCode(tc.sig); code != tc.expected {
+			t.Fatalf("signalExitCode(%v): expected %d, got: %d", tc.sig, tc.expected, code)
+		}
+	}
+}
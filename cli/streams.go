@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+const (
+	// StdinKey is the key for the program's standard input stream.
+	StdinKey ContextKey = "program.Stdin"
+	// StdoutKey is the key for the program's standard output stream.
+	StdoutKey ContextKey = "program.Stdout"
+	// StderrKey is the key for the program's standard error stream.
+	StderrKey ContextKey = "program.Stderr"
+)
+
+// Stdin returns the standard input stream of the program running with ctx.
+// It defaults to os.Stdin.
+func Stdin(ctx context.Context) io.Reader {
+	if r, ok := ctx.Value(StdinKey).(io.Reader); ok && r != nil {
+		return r
+	}
+	return os.Stdin
+}
+
+// Stdout returns the standard output stream of the program running with ctx.
+// It defaults to os.Stdout.
+func Stdout(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(StdoutKey).(io.Writer); ok && w != nil {
+		return w
+	}
+	return os.Stdout
+}
+
+// Stderr returns the standard error stream of the program running with ctx.
+// It defaults to os.Stderr.
+func Stderr(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(StderrKey).(io.Writer); ok && w != nil {
+		return w
+	}
+	return os.Stderr
+}
+
+func (p *Program) stdin() io.Reader {
+	if p.Stdin != nil {
+		return p.Stdin
+	}
+	return os.Stdin
+}
+
+func (p *Program) stdout() io.Writer {
+	if p.Stdout != nil {
+		return p.Stdout
+	}
+	return os.Stdout
+}
+
+func (p *Program) stderr() io.Writer {
+	if p.Stderr != nil {
+		return p.Stderr
+	}
+	return os.Stderr
+}
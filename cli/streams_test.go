@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Define the echoCommand, which copies its input to its output.
+type echoCommand struct{}
+
+func (cmd *echoCommand) Name() string              { return "echo" }
+func (cmd *echoCommand) Args() string              { return "" }
+func (cmd *echoCommand) ShortHelp() string         { return "Echo the input." }
+func (cmd *echoCommand) LongHelp() string          { return "Echo the input." }
+func (cmd *echoCommand) Hidden() bool              { return false }
+func (cmd *echoCommand) Register(fs *flag.FlagSet) {}
+func (cmd *echoCommand) Run(ctx context.Context, args []string) error {
+	b, err := ioutil.ReadAll(Stdin(ctx))
+	if err != nil {
+		return err
+	}
+	_, err = Stdout(ctx).Write(b)
+	return err
+}
+
+func TestProgramStreams(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	p := NewProgram()
+	p.Name = "yo"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.Commands = []Command{&echoCommand{}}
+	p.Stdin = strings.NewReader("hello")
+	p.Stdout = &stdout
+	p.Stderr = &stderr
+
+	// The command should read and write the program's streams.
+	if err := p.run(p.defaultContext(), []string{"yo", "echo"}); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "hello" {
+		t.Fatalf("expected stdout: %q\ngot: %q", "hello", stdout.String())
+	}
+
+	// The version command should write to the program's stdout.
+	stdout.Reset()
+	if err := p.run(p.defaultContext(), []string{"yo", "version"}); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != versionCommandExpectedStdout {
+		t.Fatalf("expected stdout: %q\ngot: %q", versionCommandExpectedStdout, stdout.String())
+	}
+
+	// The usage should be written to the program's stderr.
+	err := p.run(p.defaultContext(), []string{"yo", "version", "--help"})
+	compareErrors(t, err, flag.ErrHelp)
+	if stderr.String() != versionCommandExpectedHelp {
+		t.Fatalf("expected stderr: %q\ngot: %q", versionCommandExpectedHelp, stderr.String())
+	}
+}
+
+func TestStreamsDefault(t *testing.T) {
+	ctx := context.Background()
+	if Stdin(ctx) != os.Stdin {
+		t.Fatal("expected stdin to default to os.Stdin")
+	}
+	if Stdout(ctx) != os.Stdout {
+		t.Fatal("expected stdout to default to os.Stdout")
+	}
+	if Stderr(ctx) != os.Stderr {
+		t.Fatal("expected stderr to default to os.Stderr")
+	}
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// suggestionDistance returns the maximum edit distance between name, what the
+// user typed, and the name of a command for the command to be suggested. It
+// grows with the length of name, so short names are not matched with
+// unrelated commands.
+func suggestionDistance(name string) int {
+	return utf8.RuneCountInString(name) / 2
+}
+
+// noSuchCommandError returns the error for an unknown command, including
+// suggestions for the commands the user might have meant.
+func noSuchCommandError(name string, commands []Command) error {
+	suggestions := suggestCommands(name, commands)
+	switch len(suggestions) {
+	case 0:
+		return fmt.Errorf("%s: no such command", name)
+	case 1:
+		return fmt.Errorf("%s: no such command\n\nDid you mean this?\n\t%s", name, suggestions[0])
+	}
+	return fmt.Errorf("%s: no such command\n\nDid you mean one of these?\n\t%s", name, strings.Join(suggestions, "\n\t"))
+}
+
+// suggestCommands returns the names of the commands that are not hidden and
+// are close to name, either by edit distance or because name is a prefix of
+// the command's name or one of its aliases, ignoring case. The closest matches
+// come first.
+func suggestCommands(name string, commands []Command) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+
+	if len(name) < 1 {
+		return nil
+	}
+
+	var (
+		suggestions []suggestion
+		lower       = strings.ToLower(name)
+	)
+	for _, command := range commands {
+		if command.Hidden() {
+			continue
+		}
+
+		// Find the closest of the command's names.
+		best := -1
+		for _, n := range commandNames(command) {
+			n = strings.ToLower(n)
+			d := levenshtein(lower, n)
+			if strings.HasPrefix(n, lower) {
+				d = 0
+			}
+			if best < 0 || d < best {
+				best = d
+			}
+		}
+
+		if best <= suggestionDistance(name) {
+			suggestions = append(suggestions, suggestion{name: command.Name(), distance: best})
+		}
+	}
+
+	// Sort by distance, then by name.
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].name < suggestions[j].name
+	})
+
+	names := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		// Skip commands registered more than once.
+		if !contains(names, s.name) {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Keep only the previous row of the distance matrix.
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
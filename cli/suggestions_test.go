@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"test", "test", 0},
+		{"tset", "test", 2},
+		{"tst", "test", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tc := range testCases {
+		if d := levenshtein(tc.a, tc.b); d != tc.expected {
+			t.Fatalf("levenshtein(%q, %q): expected %d, got: %d", tc.a, tc.b, tc.expected, d)
+		}
+	}
+}
+
+func TestProgramSuggestions(t *testing.T) {
+	p := NewProgram()
+	p.Name = "yo"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.Commands = []Command{
+		&errorCommand{},
+		&testCommand{},
+		&ambiguousCommand{},
+		newRegistryCommand(),
+	}
+
+	testCases := []testCase{
+		{
+			description: "args: foo tset",
+			args:        []string{"foo", "tset"},
+			expectedErr: errors.New("tset: no such command\n\nDid you mean this?\n\ttest"),
+		},
+		{
+			description: "args: foo te",
+			args:        []string{"foo", "te"},
+			expectedErr: errors.New("te: no such command\n\nDid you mean one of these?\n\tteapot\n\ttest"),
+		},
+		{
+			description: "args: foo versoin",
+			args:        []string{"foo", "versoin"},
+			expectedErr: errors.New("versoin: no such command\n\nDid you mean this?\n\tversion"),
+		},
+		{
+			description: "args: foo nope",
+			args:        []string{"foo", "nope"},
+			expectedErr: errors.New("nope: no such command"),
+		},
+		{
+			description: "args: foo ab",
+			args:        []string{"foo", "ab"},
+			expectedErr: errors.New("ab: no such command"),
+		},
+		{
+			description: "args: foo TE",
+			args:        []string{"foo", "TE"},
+			expectedErr: errors.New("TE: no such command\n\nDid you mean one of these?\n\tteapot\n\ttest"),
+		},
+		{
+			description: "args: foo Tset",
+			args:        []string{"foo", "Tset"},
+			expectedErr: errors.New("Tset: no such command\n\nDid you mean this?\n\ttest"),
+		},
+		{
+			description: "args: foo regsitry",
+			args:        []string{"foo", "regsitry"},
+			expectedErr: errors.New("regsitry: no such command\n\nDid you mean this?\n\tregistry"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := p.run(p.defaultContext(), tc.args)
+			compareErrors(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package cli
+
+import "errors"
+
+// terminalState is the state of a terminal to restore.
+type terminalState struct{}
+
+// isTerminal returns false, since raw terminals are not supported on this
+// platform.
+func isTerminal(fd int) bool {
+	return false
+}
+
+// makeRaw returns an error, since raw terminals are not supported on this
+// platform.
+func makeRaw(fd int) (*terminalState, error) {
+	return nil, errors.New("raw terminals are not supported on this platform")
+}
+
+// restoreTerminal does nothing, since raw terminals are not supported on this
+// platform.
+func restoreTerminal(fd int, state *terminalState) error {
+	return nil
+}
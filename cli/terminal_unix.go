@@ -0,0 +1,56 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package cli
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// terminalState is the state of a terminal to restore.
+type terminalState struct {
+	termios syscall.Termios
+}
+
+// isTerminal returns whether fd is a terminal.
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctl(fd, ioctlGetTermios, &termios) == nil
+}
+
+// makeRaw puts the terminal fd into raw mode, so the input is read a key at
+// a time without being echoed, and returns the state to restore. Unlike a
+// fully raw terminal, the output is still processed, so "\n" starts a new
+// line.
+func makeRaw(fd int) (*terminalState, error) {
+	var state terminalState
+	if err := ioctl(fd, ioctlGetTermios, &state.termios); err != nil {
+		return nil, err
+	}
+
+	raw := state.termios
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// restoreTerminal restores the terminal fd to state.
+func restoreTerminal(fd int, state *terminalState) error {
+	return ioctl(fd, ioctlSetTermios, &state.termios)
+}
+
+func ioctl(fd int, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -34,6 +34,21 @@ func testCasesUndefinedCommand() []testCase {
 	}
 }
 
+func testCasesNoCommands() []testCase {
+	return []testCase{
+		{
+			description: "args: foo",
+			args:        []string{"foo"},
+			expectedErr: flag.ErrHelp,
+		},
+		{
+			description: "args: foo bar",
+			args:        []string{"foo", "bar"},
+			expectedErr: flag.ErrHelp,
+		},
+	}
+}
+
 func testCasesWithCommands() []testCase {
 	return []testCase{
 		{
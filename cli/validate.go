@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Required marks the flags in fs with the given names as required. The
+// program returns a usage error if one of them is not set by the arguments,
+// the environment or the config file. Required panics if one of the flags is
+// not defined.
+func Required(fs *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		meta(lookupDefined(fs, name)).required = true
+	}
+}
+
+// Enum restricts the values of the flag name in fs to the given values. The
+// program returns a usage error if the flag is set to any other value. Enum
+// panics if the flag is not defined.
+func Enum(fs *flag.FlagSet, name string, values ...string) {
+	meta(lookupDefined(fs, name)).allowed = values
+}
+
+// allower is implemented by flag values that can only be set to a fixed set of
+// values, like values.Enum, so they are shown in the usage output.
+type allower interface {
+	Allowed() []string
+}
+
+// MutuallyExclusive declares that at most one of the flags in fs with the
+// given names can be set. MutuallyExclusive panics if one of the flags is not
+// defined.
+func MutuallyExclusive(fs *flag.FlagSet, names ...string) {
+	addConstraint(fs, &flagConstraint{names: names, exclusive: true})
+}
+
+// RequiredTogether declares that either all or none of the flags in fs with
+// the given names must be set, like a username and a password.
+// RequiredTogether panics if one of the flags is not defined.
+func RequiredTogether(fs *flag.FlagSet, names ...string) {
+	addConstraint(fs, &flagConstraint{names: names})
+}
+
+// flagConstraint is a constraint on a group of flags.
+type flagConstraint struct {
+	names []string
+	// exclusive is whether at most one of the flags can be set, otherwise
+	// either all or none of them must be set.
+	exclusive bool
+}
+
+// addConstraint adds c to the metadata of the first flag of the group, so it
+// is checked once.
+func addConstraint(fs *flag.FlagSet, c *flagConstraint) {
+	for _, name := range c.names {
+		lookupDefined(fs, name)
+	}
+	if len(c.names) < 2 {
+		return
+	}
+
+	m := meta(fs.Lookup(c.names[0]))
+	m.constraints = append(m.constraints, c)
+}
+
+// validateFlags checks the parsed flags in fs against the constraints declared
+// for them, and returns all the violations as one error. The flags also
+// defined in ignore, if not nil, are not checked and do not count towards the
+// constraints of the other flags.
+func validateFlags(fs, ignore *flag.FlagSet) error {
+	set := setFlags(fs)
+
+	var violations []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if ignore != nil && ignore.Lookup(f.Name) != nil {
+			return
+		}
+		m := flagMeta(f)
+
+		if m.required && !set[f.Name] {
+			violations = append(violations, fmt.Sprintf("flag %s is required", flagName(f.Name)))
+		}
+
+		if len(m.allowed) > 0 && set[f.Name] && !contains(m.allowed, f.Value.String()) {
+			violations = append(violations, fmt.Sprintf("invalid value %q for flag %s: must be one of: %s",
+				f.Value.String(), flagName(f.Name), strings.Join(m.allowed, ", ")))
+		}
+
+		for _, c := range m.constraints {
+			var all, names []string
+			for _, name := range c.names {
+				all = append(all, flagName(name))
+				if set[name] && (ignore == nil || ignore.Lookup(name) == nil) {
+					names = append(names, flagName(name))
+				}
+			}
+
+			switch {
+			case c.exclusive && len(names) > 1:
+				violations = append(violations, fmt.Sprintf("flags %s cannot be used together", strings.Join(names, ", ")))
+			case !c.exclusive && len(names) > 0 && len(names) < len(c.names):
+				violations = append(violations, fmt.Sprintf("flags %s must be used together", strings.Join(all, ", ")))
+			}
+		}
+	})
+
+	if len(violations) > 0 {
+		return errors.New(strings.Join(violations, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+type registryFlags struct {
+	host     string
+	format   string
+	json     bool
+	yaml     bool
+	user     string
+	password string
+}
+
+func newValidateProgram(v *registryFlags) *Program {
+	p := NewProgram()
+	p.Name = "yo"
+	p.Stderr = &bytes.Buffer{}
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.FlagSet.StringVar(&v.host, "host", "", "the registry host")
+	p.FlagSet.StringVar(&v.format, "format", "table", "the output format")
+	p.FlagSet.BoolVar(&v.json, "json", false, "print JSON")
+	p.FlagSet.BoolVar(&v.yaml, "yaml", false, "print YAML")
+	p.FlagSet.StringVar(&v.user, "user", "", "the registry user")
+	p.FlagSet.StringVar(&v.password, "password", "", "the registry password")
+	Alias(p.FlagSet, "host", "H")
+	Required(p.FlagSet, "host")
+	Enum(p.FlagSet, "format", "table", "wide")
+	MutuallyExclusive(p.FlagSet, "json", "yaml", "format")
+	RequiredTogether(p.FlagSet, "user", "password")
+	p.Commands = []Command{&testCommand{}}
+	return p
+}
+
+func TestValidateFlags(t *testing.T) {
+	testCases := []struct {
+		description string
+		args        []string
+		env         map[string]string
+		expectedErr error
+	}{
+		{
+			description: "valid flags",
+			args:        []string{"yo", "test", "-host", "r.j3ss.co", "-format", "wide"},
+		},
+		{
+			description: "required flag set by its alias",
+			args:        []string{"yo", "test", "-H", "r.j3ss.co"},
+		},
+		{
+			description: "required flag set by the environment",
+			args:        []string{"yo", "test"},
+			env:         map[string]string{"YO_HOST": "r.j3ss.co"},
+		},
+		{
+			description: "missing required flag",
+			args:        []string{"yo", "test"},
+			expectedErr: errors.New("flag --host is required"),
+		},
+		{
+			description: "value not allowed",
+			args:        []string{"yo", "test", "-host", "r.j3ss.co", "-format", "xml"},
+			expectedErr: errors.New(`invalid value "xml" for flag --format: must be one of: table, wide`),
+		},
+		{
+			description: "mutually exclusive flags",
+			args:        []string{"yo", "test", "-host", "r.j3ss.co", "-json", "-yaml"},
+			expectedErr: errors.New("flags --json, --yaml cannot be used together"),
+		},
+		{
+			description: "flags required together",
+			args:        []string{"yo", "test", "-host", "r.j3ss.co", "-password", "hunter2"},
+			expectedErr: errors.New("flags --user, --password must be used together"),
+		},
+		{
+			description: "all violations at once",
+			args:        []string{"yo", "test", "-json", "-format", "xml", "-user", "jess"},
+			expectedErr: errors.New(`invalid value "xml" for flag --format: must be one of: table, wide
+flag --host is required
+flags --json, --format cannot be used together
+flags --user, --password must be used together`),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			var v registryFlags
+			p := newValidateProgram(&v)
+			p.EnvPrefix = "YO"
+
+			// Hooks should not run when the flags are invalid.
+			var ran bool
+			p.Before = func(ctx context.Context) error {
+				ran = true
+				return nil
+			}
+
+			err := p.run(p.defaultContext(), tc.args)
+			compareErrors(t, err, tc.expectedErr)
+			if tc.expectedErr != nil {
+				if code := ExitCode(err); code != ExitUsage {
+					t.Fatalf("expected exit code %d, got: %d", ExitUsage, code)
+				}
+				if ran {
+					t.Fatal("expected Before not to run")
+				}
+			}
+		})
+	}
+}
+
+func TestValidateFlagsAction(t *testing.T) {
+	var v registryFlags
+	p := newValidateProgram(&v)
+	p.Commands = nil
+	p.Action = func(ctx context.Context, args []string) error {
+		return nil
+	}
+
+	err := p.run(p.defaultContext(), []string{"yo", "-format", "xml"})
+	compareErrors(t, err, errors.New(`invalid value "xml" for flag --format: must be one of: table, wide
+flag --host is required`))
+
+	// Asking for help should not fail validation.
+	err = newValidateProgram(&v).run(p.defaultContext(), []string{"yo", "test", "-h"})
+	compareErrors(t, err, flag.ErrHelp)
+}
+
+func TestValidateFlagsBuiltins(t *testing.T) {
+	testCases := []struct {
+		args        []string
+		expectedErr error
+	}{
+		{args: []string{"yo", "version"}},
+		{args: []string{"yo", "completion", "bash"}},
+		{args: []string{"yo", "__complete", "--", "registry", "tags", "list", ""}},
+		{args: []string{"yo", "registry", "tags", "list"}, expectedErr: errors.New("flag --token is required")},
+		{args: []string{"yo", "-token", "secret", "version", "-json", "-short"}, expectedErr: errors.New("flags --json, --short cannot be used together")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var token string
+
+			// The built-in commands do not need the global flags.
+			p := NewProgram()
+			p.Name = "yo"
+			p.Stdout = ioutil.Discard
+			p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+			p.FlagSet.StringVar(&token, "token", "", "API token")
+			Required(p.FlagSet, "token")
+			p.Commands = []Command{newRegistryCommand()}
+
+			err := p.run(p.defaultContext(), tc.args)
+			compareErrors(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestValidateFlagsUsage(t *testing.T) {
+	var (
+		v      registryFlags
+		stderr bytes.Buffer
+
+		expected = `Flags:
+
+  -H, --host  the registry host (required)
+  --format    the output format (one of: table, wide) (default: table)
+  --json      print JSON (default: false)
+  --password  the registry password (default: <none>)
+  --user      the registry user (default: <none>)
+  --yaml      print YAML (default: false)
+
+`
+	)
+
+	p := newValidateProgram(&v)
+	p.resetFlagUsage(&stderr, p.FlagSet)
+	if stderr.String() != expected {
+		t.Fatalf("expected: %q\ngot: %q", expected, stderr.String())
+	}
+}
+
+func TestRequiredUndefined(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected Required to panic for an undefined flag")
+		}
+	}()
+
+	Required(flag.NewFlagSet("test", flag.ContinueOnError), "nope")
+}
+
+// enumValue is a flag.Value that knows its allowed values.
+type enumValue string
+
+func (v *enumValue) String() string     { return string(*v) }
+func (v *enumValue) Set(s string) error { *v = enumValue(s); return nil }
+func (v *enumValue) Allowed() []string  { return []string{"json", "text"} }
+
+func TestAllowerUsage(t *testing.T) {
+	var (
+		format = enumValue("text")
+		stderr bytes.Buffer
+
+		expected = `Flags:
+
+  --format  the output format (one of: json, text) (default: text)
+
+`
+	)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&format, "format", "the output format")
+
+	NewProgram().resetFlagUsage(&stderr, fs)
+	if stderr.String() != expected {
+		t.Fatalf("expected: %q\ngot: %q", expected, stderr.String())
+	}
+}
@@ -0,0 +1,136 @@
+package values
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The sizes of the decimal and binary byte units.
+const (
+	B int64 = 1
+
+	KB = 1000 * B
+	MB = 1000 * KB
+	GB = 1000 * MB
+	TB = 1000 * GB
+	PB = 1000 * TB
+
+	KiB = 1024 * B
+	MiB = 1024 * KiB
+	GiB = 1024 * MiB
+	TiB = 1024 * GiB
+	PiB = 1024 * TiB
+)
+
+// byteUnits are the units of a size, largest first. The single letter units
+// are binary, like they are for docker and ulimit.
+var byteUnits = []struct {
+	name string
+	size int64
+}{
+	{"PiB", PiB}, {"PB", PB},
+	{"TiB", TiB}, {"TB", TB},
+	{"GiB", GiB}, {"GB", GB},
+	{"MiB", MiB}, {"MB", MB},
+	{"KiB", KiB}, {"KB", KB},
+	{"B", B},
+}
+
+// byteUnitAliases are the other names of the units, in lower case.
+var byteUnitAliases = map[string]int64{
+	"":  B,
+	"k": KiB,
+	"m": MiB,
+	"g": GiB,
+	"t": TiB,
+	"p": PiB,
+}
+
+// ByteSize is a flag.Value for a size in bytes, like "512", "10MiB", "1.5GB"
+// or "64k".
+type ByteSize struct {
+	p *int64
+}
+
+// NewByteSize sets p to def and returns a ByteSize setting p.
+func NewByteSize(p *int64, def int64) *ByteSize {
+	*p = def
+	return &ByteSize{p: p}
+}
+
+// Set parses value as a size in bytes.
+func (s *ByteSize) Set(value string) error {
+	size, err := ParseByteSize(value)
+	if err != nil {
+		return err
+	}
+	*s.p = size
+	return nil
+}
+
+// String returns the size in the largest unit it is a whole number of, like
+// "10MiB".
+func (s *ByteSize) String() string {
+	if s == nil || s.p == nil {
+		return ""
+	}
+	return FormatByteSize(*s.p)
+}
+
+// Get returns the size in bytes.
+func (s *ByteSize) Get() interface{} { return *s.p }
+
+// ParseByteSize parses a size in bytes with an optional decimal or binary
+// unit, like "10MiB" or "1.5GB". Units are case insensitive.
+func ParseByteSize(value string) (int64, error) {
+	s := strings.TrimSpace(value)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r)
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	number, unit := strings.TrimSpace(s[:i]), strings.ToLower(s[i:])
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	size, ok := byteUnitAliases[unit]
+	if !ok {
+		for _, u := range byteUnits {
+			if strings.ToLower(u.name) == unit {
+				size, ok = u.size, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", value, s[i:])
+	}
+
+	// MaxInt64 is rounded up to 2^63 as a float64, which does not fit.
+	bytes := n * float64(size)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", value)
+	}
+	return int64(bytes), nil
+}
+
+// FormatByteSize formats size in the largest unit it is a whole number of,
+// like "10MiB" or "1500B".
+func FormatByteSize(size int64) string {
+	if size == 0 {
+		return "0B"
+	}
+	for _, u := range byteUnits {
+		if size%u.size == 0 {
+			return fmt.Sprintf("%d%s", size/u.size, u.name)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
@@ -0,0 +1,75 @@
+package values
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expected    int64
+		expectedErr error
+	}{
+		{value: "0", expected: 0},
+		{value: "512", expected: 512},
+		{value: "512B", expected: 512},
+		{value: "10MiB", expected: 10 * MiB},
+		{value: "10mib", expected: 10 * MiB},
+		{value: "10 MB", expected: 10 * MB},
+		{value: "1.5GB", expected: 1500 * MB},
+		{value: "64k", expected: 64 * KiB},
+		{value: "2T", expected: 2 * TiB},
+		{value: "", expectedErr: errors.New(`invalid size ""`)},
+		{value: "MiB", expectedErr: errors.New(`invalid size "MiB"`)},
+		{value: "-1", expectedErr: errors.New(`invalid size "-1"`)},
+		{value: "10XB", expectedErr: errors.New(`invalid size "10XB": unknown unit "XB"`)},
+		{value: "9000000PiB", expectedErr: errors.New(`invalid size "9000000PiB": too large`)},
+		{value: "8192PiB", expectedErr: errors.New(`invalid size "8192PiB": too large`)},
+		{value: "9223372036854775808", expectedErr: errors.New(`invalid size "9223372036854775808": too large`)},
+		{value: "8191PiB", expected: 8191 * PiB},
+	}
+
+	for _, tc := range testCases {
+		size, err := ParseByteSize(tc.value)
+		if tc.expectedErr != nil {
+			if err == nil || err.Error() != tc.expectedErr.Error() {
+				t.Fatalf("[%s] expected error %v, got: %v", tc.value, tc.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[%s] %v", tc.value, err)
+		}
+		if size != tc.expected {
+			t.Fatalf("[%s] expected %d, got: %d", tc.value, tc.expected, size)
+		}
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	testCases := []struct {
+		size     int64
+		expected string
+	}{
+		{size: 0, expected: "0B"},
+		{size: 1500, expected: "1500B"},
+		{size: 2000, expected: "2KB"},
+		{size: 10 * MiB, expected: "10MiB"},
+		{size: 1536 * MiB, expected: "1536MiB"},
+		{size: 3 * TB, expected: "3TB"},
+	}
+
+	for _, tc := range testCases {
+		if s := FormatByteSize(tc.size); s != tc.expected {
+			t.Fatalf("[%d] expected %q, got: %q", tc.size, tc.expected, s)
+		}
+	}
+
+	// The default should be parsed back to the same size.
+	var size int64
+	s := NewByteSize(&size, 10*MiB)
+	if err := s.Set(s.String()); err != nil || size != 10*MiB {
+		t.Fatalf("expected %s to parse back to %d, got: %d (%v)", s, 10*MiB, size, err)
+	}
+}
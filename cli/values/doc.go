@@ -0,0 +1,14 @@
+// Package values provides flag.Value types for the flags that the flag package
+// does not have, like repeated strings, key=value pairs and byte sizes.
+//
+// The values are defined with the Var method of a flag.FlagSet, like the
+// Program.FlagSet of a cli program or the FlagSet given to Command.Register:
+//
+//	fs.Var(values.NewStringSlice(&cmd.tags, nil), "tag", "tag to push (repeatable)")
+//	fs.Var(values.NewByteSize(&cmd.limit, 10*values.MiB), "limit", "maximum upload size")
+//
+// Each constructor sets the variable to its default, and the values render
+// their defaults in the same form they are parsed from. The lists and maps
+// are given as comma separated lists or by repeating the flag, so they can be
+// set from a single environment variable, like YO_TAG=a,b.
+package values
@@ -0,0 +1,52 @@
+package values_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/genuinetools/pkg/cli"
+	"github.com/genuinetools/pkg/cli/values"
+)
+
+type pushCommand struct {
+	tags   []string
+	labels map[string]string
+	format string
+	limit  int64
+}
+
+func (cmd *pushCommand) Name() string      { return "push" }
+func (cmd *pushCommand) Args() string      { return "<image>" }
+func (cmd *pushCommand) ShortHelp() string { return "Push an image." }
+func (cmd *pushCommand) LongHelp() string  { return "Push an image to the registry." }
+func (cmd *pushCommand) Hidden() bool      { return false }
+
+func (cmd *pushCommand) Register(fs *flag.FlagSet) {
+	fs.Var(values.NewStringSlice(&cmd.tags, []string{"latest"}), "tag", "tag to push")
+	fs.Var(values.NewStringMap(&cmd.labels, nil), "label", "label as key=value")
+	fs.Var(values.NewEnum(&cmd.format, "text", "text", "json"), "format", "output format")
+	fs.Var(values.NewByteSize(&cmd.limit, 512*values.MiB), "limit", "maximum upload size")
+}
+
+func (cmd *pushCommand) Run(ctx context.Context, args []string) error {
+	fmt.Printf("tags: %v\n", cmd.tags)
+	fmt.Printf("labels: %v\n", cmd.labels)
+	fmt.Printf("format: %s\n", cmd.format)
+	fmt.Printf("limit: %d\n", cmd.limit)
+	return nil
+}
+
+func Example() {
+	p := cli.NewProgram()
+	p.Name = "reg"
+	p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+	p.Commands = []cli.Command{&pushCommand{}}
+
+	p.RunContext(context.Background(), []string{"reg", "push", "-tag", "v1", "-tag", "v1.2", "-label", "team=infra", "-limit", "1GiB", "alpine"})
+	// Output:
+	// tags: [v1 v1.2]
+	// labels: map[team:infra]
+	// format: text
+	// limit: 1073741824
+}
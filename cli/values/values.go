@@ -0,0 +1,327 @@
+package values
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StringSlice is a flag.Value for a list of strings, given as a comma separated
+// list or by repeating the flag, like "--tag a,b --tag c". The first time it is
+// set it replaces the default.
+type StringSlice struct {
+	p   *[]string
+	def []string
+	set bool
+}
+
+// NewStringSlice sets p to def and returns a StringSlice setting p.
+func NewStringSlice(p *[]string, def []string) *StringSlice {
+	*p = def
+	return &StringSlice{p: p, def: def}
+}
+
+// Set appends the strings in value to the slice.
+func (s *StringSlice) Set(value string) error {
+	if !s.set {
+		*s.p, s.set = nil, true
+	}
+	*s.p = append(*s.p, strings.Split(value, ",")...)
+	return nil
+}
+
+// String returns the values separated by commas.
+func (s *StringSlice) String() string {
+	// The flag package calls String on the zero value to find the default.
+	if s == nil || s.p == nil {
+		return ""
+	}
+	return strings.Join(*s.p, ",")
+}
+
+// Get returns the slice of values.
+func (s *StringSlice) Get() interface{} { return *s.p }
+
+// Reset sets the slice back to the default, so the next Set replaces it again.
+func (s *StringSlice) Reset() { *s.p, s.set = s.def, false }
+
+// StringMap is a flag.Value for key=value pairs, given as a comma separated
+// list or by repeating the flag, like "--label env=prod,team=infra --label
+// app=reg". The first time it is set it replaces the default.
+type StringMap struct {
+	p   *map[string]string
+	def map[string]string
+	set bool
+}
+
+// NewStringMap sets p to def and returns a StringMap setting p.
+func NewStringMap(p *map[string]string, def map[string]string) *StringMap {
+	*p = def
+	return &StringMap{p: p, def: def}
+}
+
+// Set adds the key=value pairs in value to the map.
+func (m *StringMap) Set(value string) error {
+	pairs := strings.Split(value, ",")
+	for _, pair := range pairs {
+		if strings.Index(pair, "=") < 1 {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+	}
+
+	if !m.set || *m.p == nil {
+		*m.p, m.set = map[string]string{}, true
+	}
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		(*m.p)[pair[:i]] = pair[i+1:]
+	}
+	return nil
+}
+
+// String returns the pairs sorted by key and separated by commas, like
+// "env=prod,team=infra".
+func (m *StringMap) String() string {
+	if m == nil || m.p == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(*m.p))
+	for k, v := range *m.p {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Get returns the map of values.
+func (m *StringMap) Get() interface{} { return *m.p }
+
+// Reset sets the map back to the default, so the next Set replaces it again.
+func (m *StringMap) Reset() { *m.p, m.set = m.def, false }
+
+// Enum is a flag.Value for a string flag that can only be set to one of a
+// fixed set of values, like "--format json".
+type Enum struct {
+	p       *string
+	allowed []string
+}
+
+// NewEnum sets p to def and returns an Enum setting p to one of the allowed
+// values.
+func NewEnum(p *string, def string, allowed ...string) *Enum {
+	*p = def
+	return &Enum{p: p, allowed: allowed}
+}
+
+// Set sets the value if it is allowed.
+func (e *Enum) Set(value string) error {
+	for _, a := range e.allowed {
+		if value == a {
+			*e.p = value
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of: %s", strings.Join(e.allowed, ", "))
+}
+
+// String returns the value.
+func (e *Enum) String() string {
+	if e == nil || e.p == nil {
+		return ""
+	}
+	return *e.p
+}
+
+// Get returns the value.
+func (e *Enum) Get() interface{} { return *e.p }
+
+// Allowed returns the values the flag can be set to.
+func (e *Enum) Allowed() []string { return e.allowed }
+
+// DurationSlice is a flag.Value for a list of durations, given as a comma
+// separated list or by repeating the flag, like "--retry 1s,5s --retry 30s".
+// The first time it is set it replaces the default.
+type DurationSlice struct {
+	p   *[]time.Duration
+	def []time.Duration
+	set bool
+}
+
+// NewDurationSlice sets p to def and returns a DurationSlice setting p.
+func NewDurationSlice(p *[]time.Duration, def []time.Duration) *DurationSlice {
+	*p = def
+	return &DurationSlice{p: p, def: def}
+}
+
+// Set appends the durations in value to the slice.
+func (s *DurationSlice) Set(value string) error {
+	var durations []time.Duration
+	for _, v := range strings.Split(value, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		durations = append(durations, d)
+	}
+
+	if !s.set {
+		*s.p, s.set = nil, true
+	}
+	*s.p = append(*s.p, durations...)
+	return nil
+}
+
+// String returns the durations separated by commas.
+func (s *DurationSlice) String() string {
+	if s == nil || s.p == nil {
+		return ""
+	}
+
+	durations := make([]string, 0, len(*s.p))
+	for _, d := range *s.p {
+		durations = append(durations, d.String())
+	}
+	return strings.Join(durations, ",")
+}
+
+// Get returns the slice of durations.
+func (s *DurationSlice) Get() interface{} { return *s.p }
+
+// Reset sets the slice back to the default, so the next Set replaces it again.
+func (s *DurationSlice) Reset() { *s.p, s.set = s.def, false }
+
+// IP is a flag.Value for an IPv4 or IPv6 address.
+type IP struct {
+	p *net.IP
+}
+
+// NewIP sets p to def and returns an IP setting p.
+func NewIP(p *net.IP, def net.IP) *IP {
+	*p = def
+	return &IP{p: p}
+}
+
+// Set parses value as an IP address.
+func (ip *IP) Set(value string) error {
+	parsed := net.ParseIP(value)
+	if parsed == nil {
+		return fmt.Errorf("invalid IP address %q", value)
+	}
+	*ip.p = parsed
+	return nil
+}
+
+// String returns the address, or an empty string if it is not set.
+func (ip *IP) String() string {
+	if ip == nil || ip.p == nil || *ip.p == nil {
+		return ""
+	}
+	return ip.p.String()
+}
+
+// Get returns the address.
+func (ip *IP) Get() interface{} { return *ip.p }
+
+// IPNet is a flag.Value for a network in CIDR notation, like "10.0.0.0/8".
+type IPNet struct {
+	p **net.IPNet
+}
+
+// NewIPNet sets p to def and returns an IPNet setting p.
+func NewIPNet(p **net.IPNet, def *net.IPNet) *IPNet {
+	*p = def
+	return &IPNet{p: p}
+}
+
+// Set parses value as a network in CIDR notation.
+func (n *IPNet) Set(value string) error {
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return err
+	}
+	*n.p = network
+	return nil
+}
+
+// String returns the network, or an empty string if it is not set.
+func (n *IPNet) String() string {
+	if n == nil || n.p == nil || *n.p == nil {
+		return ""
+	}
+	return (*n.p).String()
+}
+
+// Get returns the network.
+func (n *IPNet) Get() interface{} { return *n.p }
+
+// URL is a flag.Value for an absolute URL, like "https://r.j3ss.co".
+type URL struct {
+	p **url.URL
+}
+
+// NewURL sets p to def and returns a URL setting p.
+func NewURL(p **url.URL, def *url.URL) *URL {
+	*p = def
+	return &URL{p: p}
+}
+
+// Set parses value as an absolute URL.
+func (u *URL) Set(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if !parsed.IsAbs() {
+		return fmt.Errorf("URL %q is missing a scheme", value)
+	}
+	*u.p = parsed
+	return nil
+}
+
+// String returns the URL, or an empty string if it is not set.
+func (u *URL) String() string {
+	if u == nil || u.p == nil || *u.p == nil {
+		return ""
+	}
+	return (*u.p).String()
+}
+
+// Get returns the URL.
+func (u *URL) Get() interface{} { return *u.p }
+
+// Regexp is a flag.Value for a regular expression.
+type Regexp struct {
+	p **regexp.Regexp
+}
+
+// NewRegexp sets p to def and returns a Regexp setting p.
+func NewRegexp(p **regexp.Regexp, def *regexp.Regexp) *Regexp {
+	*p = def
+	return &Regexp{p: p}
+}
+
+// Set compiles value as a regular expression.
+func (r *Regexp) Set(value string) error {
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+	*r.p = re
+	return nil
+}
+
+// String returns the regular expression, or an empty string if it is not set.
+func (r *Regexp) String() string {
+	if r == nil || r.p == nil || *r.p == nil {
+		return ""
+	}
+	return (*r.p).String()
+}
+
+// Get returns the compiled regular expression.
+func (r *Regexp) Get() interface{} { return *r.p }
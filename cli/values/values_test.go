@@ -0,0 +1,234 @@
+package values
+
+import (
+	"flag"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+func TestStringSlice(t *testing.T) {
+	var tags []string
+	fs := newFlagSet()
+	fs.Var(NewStringSlice(&tags, []string{"latest"}), "tag", "")
+
+	if def := fs.Lookup("tag").DefValue; def != "latest" {
+		t.Fatalf("expected default latest, got: %q", def)
+	}
+	if err := fs.Parse([]string{"-tag", "a", "-tag", "b,c"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("expected %v, got: %v", expected, tags)
+	}
+
+	// The value should be parsed back from its string.
+	var copied []string
+	if err := NewStringSlice(&copied, nil).Set(fs.Lookup("tag").Value.String()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(copied, tags) {
+		t.Fatalf("expected %v, got: %v", tags, copied)
+	}
+
+	// Reset should restore the default, which the next Set replaces.
+	fs.Lookup("tag").Value.(*StringSlice).Reset()
+	if expected := []string{"latest"}; !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("expected %v, got: %v", expected, tags)
+	}
+	if err := fs.Set("tag", "d"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"d"}; !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("expected %v, got: %v", expected, tags)
+	}
+}
+
+func TestStringMap(t *testing.T) {
+	var labels map[string]string
+	fs := newFlagSet()
+	fs.Var(NewStringMap(&labels, map[string]string{"team": "infra", "env": "dev"}), "label", "")
+
+	if def := fs.Lookup("label").DefValue; def != "env=dev,team=infra" {
+		t.Fatalf("expected default env=dev,team=infra, got: %q", def)
+	}
+	if err := fs.Parse([]string{"-label", "env=prod", "-label", "query=a=b,team=reg"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"env": "prod", "query": "a=b", "team": "reg"}; !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("expected %v, got: %v", expected, labels)
+	}
+
+	// The value should be parsed back from its string.
+	var copied map[string]string
+	if err := NewStringMap(&copied, nil).Set(fs.Lookup("label").Value.String()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(copied, labels) {
+		t.Fatalf("expected %v, got: %v", labels, copied)
+	}
+
+	// Invalid pairs should not set any of the pairs.
+	err := fs.Set("label", "app=reg,nope")
+	if err == nil || err.Error() != `expected key=value, got "nope"` {
+		t.Fatalf("expected an error for a missing =, got: %v", err)
+	}
+	if _, ok := labels["app"]; ok {
+		t.Fatalf("expected app not to be set, got: %v", labels)
+	}
+
+	// Reset should restore the default, which the next Set replaces.
+	fs.Lookup("label").Value.(*StringMap).Reset()
+	if expected := map[string]string{"team": "infra", "env": "dev"}; !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("expected %v, got: %v", expected, labels)
+	}
+	if err := fs.Set("label", "app=reg"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"app": "reg"}; !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("expected %v, got: %v", expected, labels)
+	}
+}
+
+func TestEnum(t *testing.T) {
+	var format string
+	fs := newFlagSet()
+	e := NewEnum(&format, "table", "table", "json")
+	fs.Var(e, "format", "")
+
+	if err := fs.Set("format", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if format != "json" {
+		t.Fatalf("expected json, got: %q", format)
+	}
+
+	err := fs.Set("format", "xml")
+	if err == nil || err.Error() != "must be one of: table, json" {
+		t.Fatalf("expected an error for a value not allowed, got: %v", err)
+	}
+	if !reflect.DeepEqual(e.Allowed(), []string{"table", "json"}) {
+		t.Fatalf("expected the allowed values, got: %v", e.Allowed())
+	}
+}
+
+func TestDurationSlice(t *testing.T) {
+	var retries []time.Duration
+	fs := newFlagSet()
+	fs.Var(NewDurationSlice(&retries, []time.Duration{time.Second}), "retry", "")
+
+	if def := fs.Lookup("retry").DefValue; def != "1s" {
+		t.Fatalf("expected default 1s, got: %q", def)
+	}
+	if err := fs.Parse([]string{"-retry", "1s, 5s", "-retry", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []time.Duration{time.Second, 5 * time.Second, time.Minute}; !reflect.DeepEqual(retries, expected) {
+		t.Fatalf("expected %v, got: %v", expected, retries)
+	}
+	if err := fs.Set("retry", "soon"); err == nil {
+		t.Fatal("expected an error for an invalid duration")
+	}
+
+	// Reset should restore the default, which the next Set replaces.
+	fs.Lookup("retry").Value.(*DurationSlice).Reset()
+	if expected := []time.Duration{time.Second}; !reflect.DeepEqual(retries, expected) {
+		t.Fatalf("expected %v, got: %v", expected, retries)
+	}
+	if err := fs.Set("retry", "2s"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []time.Duration{2 * time.Second}; !reflect.DeepEqual(retries, expected) {
+		t.Fatalf("expected %v, got: %v", expected, retries)
+	}
+}
+
+func TestNetValues(t *testing.T) {
+	var (
+		ip      net.IP
+		network *net.IPNet
+	)
+	fs := newFlagSet()
+	fs.Var(NewIP(&ip, nil), "ip", "")
+	fs.Var(NewIPNet(&network, nil), "cidr", "")
+
+	if def := fs.Lookup("ip").DefValue + fs.Lookup("cidr").DefValue; def != "" {
+		t.Fatalf("expected empty defaults, got: %q", def)
+	}
+	if err := fs.Parse([]string{"-ip", "::1", "-cidr", "10.1.2.3/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.IPv6loopback) {
+		t.Fatalf("expected ::1, got: %v", ip)
+	}
+	if network.String() != "10.0.0.0/8" {
+		t.Fatalf("expected 10.0.0.0/8, got: %v", network)
+	}
+
+	if err := fs.Set("ip", "10.0.0.256"); err == nil || err.Error() != `invalid IP address "10.0.0.256"` {
+		t.Fatalf("expected an error for an invalid IP, got: %v", err)
+	}
+	if err := fs.Set("cidr", "10.0.0.0"); err == nil {
+		t.Fatal("expected an error for a network without a prefix length")
+	}
+}
+
+func TestURL(t *testing.T) {
+	var u *url.URL
+	def, _ := url.Parse("https://r.j3ss.co")
+	fs := newFlagSet()
+	fs.Var(NewURL(&u, def), "registry", "")
+
+	if def := fs.Lookup("registry").DefValue; def != "https://r.j3ss.co" {
+		t.Fatalf("expected default https://r.j3ss.co, got: %q", def)
+	}
+	if err := fs.Set("registry", "http://localhost:5000/v2"); err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "localhost:5000" {
+		t.Fatalf("expected host localhost:5000, got: %q", u.Host)
+	}
+	if err := fs.Set("registry", "localhost"); err == nil || !strings.Contains(err.Error(), "missing a scheme") {
+		t.Fatalf("expected an error for a relative URL, got: %v", err)
+	}
+}
+
+func TestRegexp(t *testing.T) {
+	var re *regexp.Regexp
+	fs := newFlagSet()
+	fs.Var(NewRegexp(&re, nil), "match", "")
+
+	if err := fs.Set("match", "^v[0-9]+$"); err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("v2") {
+		t.Fatalf("expected %s to match v2", re)
+	}
+	if err := fs.Set("match", "("); err == nil {
+		t.Fatal("expected an error for an invalid regular expression")
+	}
+}
+
+func TestZeroValueString(t *testing.T) {
+	// The flag package calls String on the zero value of the type to check
+	// whether the default is the zero value.
+	for _, v := range []flag.Value{
+		&StringSlice{}, &StringMap{}, &Enum{}, &DurationSlice{},
+		&IP{}, &IPNet{}, &URL{}, &Regexp{}, &ByteSize{},
+	} {
+		if s := v.String(); s != "" {
+			t.Fatalf("expected the zero %T to be empty, got: %q", v, s)
+		}
+	}
+}
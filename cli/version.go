@@ -2,8 +2,10 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"runtime"
 )
 
@@ -15,18 +17,107 @@ func (cmd *versionCommand) ShortHelp() string { return versionHelp }
 func (cmd *versionCommand) LongHelp() string  { return versionHelp }
 func (cmd *versionCommand) Hidden() bool      { return false }
 
-func (cmd *versionCommand) Register(fs *flag.FlagSet) {}
+func (cmd *versionCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "print the version information as JSON")
+	fs.BoolVar(&cmd.short, "short", false, "print only the version")
+	MutuallyExclusive(fs, "json", "short")
+}
+
+type versionCommand struct {
+	json  bool
+	short bool
+}
 
-type versionCommand struct{}
+// versionInfo is the version information of the program, as printed by the
+// version command.
+type versionInfo struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	GitCommit     string `json:"gitCommit"`
+	GitDirty      bool   `json:"gitDirty,omitempty"`
+	GitCommitTime string `json:"gitCommitTime,omitempty"`
+	GoVersion     string `json:"goVersion"`
+	GoCompiler    string `json:"goCompiler"`
+	Platform      string `json:"platform"`
+}
 
 func (cmd *versionCommand) Run(ctx context.Context, args []string) error {
-	fmt.Printf(`%s:
+	return cmd.print(Stdout(ctx), newVersionInfo(ctx, readBuildInfo()))
+}
+
+// print writes info to w in the format chosen with the flags.
+func (cmd *versionCommand) print(w io.Writer, info versionInfo) error {
+	switch {
+	case cmd.short:
+		_, err := fmt.Fprintln(w, info.Version)
+		return err
+	case cmd.json:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	gitCommit := info.GitCommit
+	if info.GitDirty {
+		gitCommit += " (dirty)"
+	}
+	fmt.Fprintf(w, `%s:
  version     : %s
  git hash    : %s
- go version  : %s
+`, info.Name, info.Version, gitCommit)
+	if info.GitCommitTime != "" {
+		fmt.Fprintf(w, " commit time : %s\n", info.GitCommitTime)
+	}
+	_, err := fmt.Fprintf(w, ` go version  : %s
  go compiler : %s
- platform    : %s/%s
-`, ctx.Value(NameKey).(string), ctx.Value(VersionKey).(string), ctx.Value(GitCommitKey).(string),
-		runtime.Version(), runtime.Compiler, runtime.GOOS, runtime.GOARCH)
-	return nil
+ platform    : %s
+`, info.GoVersion, info.GoCompiler, info.Platform)
+	return err
+}
+
+// newVersionInfo returns the version information of the program running with
+// ctx. The Version and GitCommit not set on the program, like with -ldflags,
+// are filled in from build.
+func newVersionInfo(ctx context.Context, build buildInfo) versionInfo {
+	info := versionInfo{
+		Name:       contextString(ctx, NameKey),
+		Version:    contextString(ctx, VersionKey),
+		GitCommit:  contextString(ctx, GitCommitKey),
+		GoVersion:  runtime.Version(),
+		GoCompiler: runtime.Compiler,
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if (info.Version == "" || info.Version == defaultVersion) && build.version != "" {
+		info.Version = build.version
+	}
+	// Only use the state of the tree if the commit is the one it was built
+	// from.
+	if info.GitCommit == "" && build.revision != "" {
+		info.GitCommit = build.revision
+		info.GitDirty = build.modified
+		info.GitCommitTime = build.time
+	}
+	return info
+}
+
+// contextString returns the string value for key in ctx, or an empty string if
+// there is none.
+func contextString(ctx context.Context, key ContextKey) string {
+	s, _ := ctx.Value(key).(string)
+	return s
+}
+
+// buildInfo is the version control information embedded in the binary by the
+// go command.
+type buildInfo struct {
+	// version is the version of the main module, if it was built from a
+	// module version rather than a checkout.
+	version string
+	// revision is the commit the binary was built from.
+	revision string
+	// time is the time of the commit, in RFC 3339 format.
+	time string
+	// modified is whether the checkout had uncommitted changes.
+	modified bool
 }
@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"runtime"
+	"testing"
+)
+
+func TestVersionCommand(t *testing.T) {
+	testCases := []struct {
+		description string
+		args        []string
+		expected    string
+		expectedErr error
+	}{
+		{
+			description: "text",
+			args:        []string{"yo", "version"},
+			expected:    "yo:\n version     : v0.1.0\n git hash    : abc123\n go version  : " + runtime.Version() + "\n go compiler : " + runtime.Compiler + "\n platform    : " + runtime.GOOS + "/" + runtime.GOARCH + "\n",
+		},
+		{
+			description: "short",
+			args:        []string{"yo", "version", "--short"},
+			expected:    "v0.1.0\n",
+		},
+		{
+			description: "json",
+			args:        []string{"yo", "version", "--json"},
+			expected: `{
+  "name": "yo",
+  "version": "v0.1.0",
+  "gitCommit": "abc123",
+  "goVersion": "` + runtime.Version() + `",
+  "goCompiler": "` + runtime.Compiler + `",
+  "platform": "` + runtime.GOOS + "/" + runtime.GOARCH + `"
+}
+`,
+		},
+		{
+			description: "json and short",
+			args:        []string{"yo", "version", "--json", "--short"},
+			expectedErr: errors.New("flags --json, --short cannot be used together"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			p := NewProgram()
+			p.Name = "yo"
+			p.Version = "v0.1.0"
+			p.GitCommit = "abc123"
+			p.FlagSet = flag.NewFlagSet("global", flag.ContinueOnError)
+			p.Commands = []Command{&testCommand{}}
+			p.Stdout = &stdout
+			p.Stderr = &bytes.Buffer{}
+
+			err := p.run(p.defaultContext(), tc.args)
+			compareErrors(t, err, tc.expectedErr)
+			if stdout.String() != tc.expected {
+				t.Fatalf("expected: %q\ngot: %q", tc.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestVersionCommandGlobalFlags(t *testing.T) {
+	var (
+		v      registryFlags
+		stdout bytes.Buffer
+	)
+
+	// The global --json flag should take the place of the flag of the
+	// version command, without the constraints of the global flags.
+	p := newValidateProgram(&v)
+	p.Version = "v0.1.0"
+	p.Stdout = &stdout
+	if err := p.run(p.defaultContext(), []string{"yo", "version", "--json", "--short"}); err != nil {
+		t.Fatal(err)
+	}
+	if !v.json {
+		t.Fatal("expected the global json flag to be set")
+	}
+	if stdout.String() != "v0.1.0\n" {
+		t.Fatalf("expected the short version, got: %q", stdout.String())
+	}
+}
+
+func TestVersionCommandWithoutContext(t *testing.T) {
+	var stdout bytes.Buffer
+	ctx := context.WithValue(context.Background(), StdoutKey, &stdout)
+
+	// The version command should not panic when run without the values of
+	// the program in the context.
+	cmd := &versionCommand{json: true}
+	if err := cmd.Run(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var info versionInfo
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Fatalf("expected the go version %q, got: %q", runtime.Version(), info.GoVersion)
+	}
+}
+
+func TestNewVersionInfo(t *testing.T) {
+	build := buildInfo{
+		version:  "v1.2.3",
+		revision: "def456",
+		time:     "2026-01-02T03:04:05Z",
+		modified: true,
+	}
+
+	testCases := []struct {
+		description string
+		version     string
+		gitCommit   string
+		expected    versionInfo
+	}{
+		{
+			description: "set on the program",
+			version:     "v0.1.0",
+			gitCommit:   "abc123",
+			expected:    versionInfo{Version: "v0.1.0", GitCommit: "abc123"},
+		},
+		{
+			description: "from the build information",
+			version:     "",
+			expected:    versionInfo{Version: "v1.2.3", GitCommit: "def456", GitDirty: true, GitCommitTime: "2026-01-02T03:04:05Z"},
+		},
+		{
+			description: "default version",
+			version:     defaultVersion,
+			gitCommit:   "abc123",
+			expected:    versionInfo{Version: "v1.2.3", GitCommit: "abc123"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), VersionKey, tc.version)
+			ctx = context.WithValue(ctx, GitCommitKey, tc.gitCommit)
+
+			info := newVersionInfo(ctx, build)
+			info.GoVersion, info.GoCompiler, info.Platform = "", "", ""
+			if info != tc.expected {
+				t.Fatalf("expected: %+v\ngot: %+v", tc.expected, info)
+			}
+		})
+	}
+}
+
+func TestVersionCommandPrint(t *testing.T) {
+	info := versionInfo{
+		Name:          "yo",
+		Version:       "v1.2.3",
+		GitCommit:     "def456",
+		GitDirty:      true,
+		GitCommitTime: "2026-01-02T03:04:05Z",
+		GoVersion:     "go1.10",
+		GoCompiler:    "gc",
+		Platform:      "linux/amd64",
+	}
+
+	testCases := []struct {
+		description string
+		cmd         *versionCommand
+		expected    string
+	}{
+		{
+			description: "text",
+			cmd:         &versionCommand{},
+			expected: `yo:
+ version     : v1.2.3
+ git hash    : def456 (dirty)
+ commit time : 2026-01-02T03:04:05Z
+ go version  : go1.10
+ go compiler : gc
+ platform    : linux/amd64
+`,
+		},
+		{
+			description: "json",
+			cmd:         &versionCommand{json: true},
+			expected: `{
+  "name": "yo",
+  "version": "v1.2.3",
+  "gitCommit": "def456",
+  "gitDirty": true,
+  "gitCommitTime": "2026-01-02T03:04:05Z",
+  "goVersion": "go1.10",
+  "goCompiler": "gc",
+  "platform": "linux/amd64"
+}
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tc.cmd.print(&b, info); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tc.expected {
+				t.Fatalf("expected: %q\ngot: %q", tc.expected, b.String())
+			}
+		})
+	}
+}